@@ -0,0 +1,502 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultUser is the user of a new connection, it can run every command
+// without a password until it is changed by ACLSetUser
+const DefaultUser = "default"
+
+// categories known besides the groups of the registered commands
+var aclCategories = map[string]bool{
+	"all": true, "read": true, "write": true, "fast": true, "slow": true, "dangerous": true,
+	"string": true, "hash": true, "set": true, "generic": true, "server": true,
+	"connection": true, "pubsub": true, "memcached": true,
+}
+
+// ACLCategories returns the acl categories of the command, all, read or
+// write, fast or slow, the group and the extra categories of the spec
+func (info CommandInfo) ACLCategories() []string {
+	categories := []string{"all"}
+	if info.Flags&FlagReadOnly != 0 {
+		categories = append(categories, "read")
+	}
+	if info.Flags&FlagWrite != 0 {
+		categories = append(categories, "write")
+	}
+	if info.Flags&FlagFast != 0 {
+		categories = append(categories, "fast")
+	} else {
+		categories = append(categories, "slow")
+	}
+	if info.Group != "" {
+		categories = append(categories, info.Group)
+	}
+	return append(categories, info.Categories...)
+}
+
+// aclRule allows or denies a category or a command, the last matching rule wins
+type aclRule struct {
+	allow    bool
+	category string
+	command  string
+}
+
+func (r aclRule) String() string {
+	sign := "-"
+	if r.allow {
+		sign = "+"
+	}
+	if r.category != "" {
+		return sign + "@" + r.category
+	}
+	return sign + r.command
+}
+
+type aclUser struct {
+	name    string
+	enabled bool
+	nopass  bool
+	// sha256 of the passwords in hex
+	passwords map[string]struct{}
+	rules     []aclRule
+	keys      []string
+	channels  []string
+}
+
+func newACLUser(name string) *aclUser {
+	return &aclUser{name: name, passwords: make(map[string]struct{})}
+}
+
+func (u *aclUser) clone() *aclUser {
+	c := *u
+	c.passwords = make(map[string]struct{}, len(u.passwords))
+	for hash := range u.passwords {
+		c.passwords[hash] = struct{}{}
+	}
+	c.rules = append([]aclRule(nil), u.rules...)
+	c.keys = append([]string(nil), u.keys...)
+	c.channels = append([]string(nil), u.channels...)
+	return &c
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// apply a rule of ACL SETUSER, known checks the categories of +@ and -@
+func (u *aclUser) apply(rule string, known func(category string) bool) error {
+	lower := strings.ToLower(rule)
+	switch lower {
+	case "on":
+		u.enabled = true
+	case "off":
+		u.enabled = false
+	case "nopass":
+		u.nopass = true
+		u.passwords = make(map[string]struct{})
+	case "resetpass":
+		u.nopass = false
+		u.passwords = make(map[string]struct{})
+	case "allkeys":
+		u.keys = []string{"*"}
+	case "resetkeys":
+		u.keys = nil
+	case "allchannels":
+		u.channels = []string{"*"}
+	case "resetchannels":
+		u.channels = nil
+	case "allcommands":
+		u.rules = []aclRule{{allow: true, category: "all"}}
+	case "nocommands":
+		u.rules = nil
+	case "reset":
+		*u = *newACLUser(u.name)
+	default:
+		return u.applyPrefixed(rule, known)
+	}
+	return nil
+}
+
+func (u *aclUser) applyPrefixed(rule string, known func(category string) bool) error {
+	if len(rule) < 2 {
+		return fmt.Errorf("%w: syntax error in ACL rule '%s'", ErrInvalidArgument, rule)
+	}
+	arg := rule[1:]
+	switch rule[0] {
+	case '>':
+		u.passwords[hashPassword(arg)] = struct{}{}
+		u.nopass = false
+	case '<':
+		delete(u.passwords, hashPassword(arg))
+	case '#':
+		if len(arg) != sha256.Size*2 {
+			return fmt.Errorf("%w: the password hash must be exactly 64 characters", ErrInvalidArgument)
+		}
+		u.passwords[strings.ToLower(arg)] = struct{}{}
+		u.nopass = false
+	case '!':
+		delete(u.passwords, strings.ToLower(arg))
+	case '~':
+		u.keys = append(u.keys, arg)
+	case '&':
+		u.channels = append(u.channels, arg)
+	case '+', '-':
+		r := aclRule{allow: rule[0] == '+'}
+		if arg[0] == '@' {
+			r.category = strings.ToLower(arg[1:])
+			if !known(r.category) {
+				return fmt.Errorf("%w: unknown command category '%s'", ErrInvalidArgument, r.category)
+			}
+			if r.category == "all" {
+				// overrides every rule before
+				u.rules = nil
+			}
+		} else {
+			r.command = strings.ToLower(arg)
+		}
+		u.rules = append(u.rules, r)
+	default:
+		return fmt.Errorf("%w: syntax error in ACL rule '%s'", ErrInvalidArgument, rule)
+	}
+	return nil
+}
+
+// knownCategory reports whether category is an acl category, or the group or
+// a category of a built-in or registered command
+func (c *core) knownCategory(category string) bool {
+	if aclCategories[category] {
+		return true
+	}
+	c.commandsMu.RLock()
+	defer c.commandsMu.RUnlock()
+	for _, commands := range []map[string]*command{builtinCommands, c.commands} {
+		for _, cmd := range commands {
+			if cmd.spec.Group == category {
+				return true
+			}
+			for _, cat := range cmd.spec.Categories {
+				if cat == category {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (u *aclUser) canRun(info CommandInfo) bool {
+	allowed := false
+	categories := info.ACLCategories()
+	for _, r := range u.rules {
+		if r.command == info.Name {
+			allowed = r.allow
+			continue
+		}
+		for _, cat := range categories {
+			if r.category == cat {
+				allowed = r.allow
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+func (u *aclUser) canAccessKey(key string) bool {
+	for _, pattern := range u.keys {
+		if globMatch(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccessChannel matches channel with the channel patterns, a pattern of
+// PSUBSCRIBE must be one of the patterns unless every channel is allowed
+func (u *aclUser) canAccessChannel(channel string, isPattern bool) bool {
+	for _, pattern := range u.channels {
+		if pattern == "*" || pattern == channel || (!isPattern && globMatch(pattern, channel)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *aclUser) commandRules() string {
+	rules := make([]string, 0, len(u.rules)+1)
+	if len(u.rules) == 0 || u.rules[0].category != "all" {
+		rules = append(rules, "-@all")
+	}
+	for _, r := range u.rules {
+		rules = append(rules, r.String())
+	}
+	return strings.Join(rules, " ")
+}
+
+func (u *aclUser) info() ACLUserInfo {
+	return ACLUserInfo{
+		Name:      u.name,
+		Enabled:   u.enabled,
+		NoPass:    u.nopass,
+		Passwords: sortedNames(u.passwords),
+		Commands:  u.commandRules(),
+		Keys:      append([]string{}, u.keys...),
+		Channels:  append([]string{}, u.channels...),
+	}
+}
+
+// ACLUserInfo describes a user for ACL GETUSER and ACL LIST
+type ACLUserInfo struct {
+	Name    string
+	Enabled bool
+	NoPass  bool
+	// Passwords are the sha256 of the passwords in hex
+	Passwords []string
+	// Commands are the command rules like "-@all +@read +set"
+	Commands string
+	Keys     []string
+	Channels []string
+}
+
+// Rules returns the rules of the user like ACL LIST
+func (info ACLUserInfo) Rules() string {
+	rules := []string{"off"}
+	if info.Enabled {
+		rules[0] = "on"
+	}
+	if info.NoPass {
+		rules = append(rules, "nopass")
+	}
+	for _, hash := range info.Passwords {
+		rules = append(rules, "#"+hash)
+	}
+	for _, key := range info.Keys {
+		rules = append(rules, "~"+key)
+	}
+	for _, channel := range info.Channels {
+		rules = append(rules, "&"+channel)
+	}
+	rules = append(rules, info.Commands)
+	return strings.Join(rules, " ")
+}
+
+// acl is the users shared by all databases, it has its own lock so
+// authentication never waits for the data lock
+type acl struct {
+	mu    sync.RWMutex
+	users map[string]*aclUser
+}
+
+func newACL() *acl {
+	def := newACLUser(DefaultUser)
+	for _, rule := range []string{"on", "nopass", "allkeys", "allchannels", "allcommands"} {
+		def.apply(rule, nil)
+	}
+	return &acl{users: map[string]*aclUser{DefaultUser: def}}
+}
+
+// user returns the enabled user of name, NOPERM if it doesn't exist or is disabled
+func (a *acl) user(name string) (*aclUser, error) {
+	u := a.users[name]
+	if u == nil || !u.enabled {
+		return nil, fmt.Errorf("%w User %s is disabled or doesn't exist", ErrNoPermission, name)
+	}
+	return u, nil
+}
+
+func (a *acl) check(user string, info CommandInfo, keys []string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	u, err := a.user(user)
+	if err != nil {
+		return err
+	}
+	if !u.canRun(info) {
+		return fmt.Errorf("%w User %s has no permissions to run the '%s' command", ErrNoPermission, user, info.Name)
+	}
+	for _, key := range keys {
+		if !u.canAccessKey(key) {
+			return fmt.Errorf("%w No permissions to access a key", ErrNoPermission)
+		}
+	}
+	return nil
+}
+
+func (a *acl) checkChannel(user, channel string, isPattern bool) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	u, err := a.user(user)
+	if err != nil {
+		return err
+	}
+	if !u.canAccessChannel(channel, isPattern) {
+		return fmt.Errorf("%w No permissions to access a channel", ErrNoPermission)
+	}
+	return nil
+}
+
+// commandKeys returns the keys of r by the key positions of the spec
+func commandKeys(cmd *command, r IResult) []string {
+	args := r.Args()
+	var keys []string
+	for _, pos := range keyPositions(cmd.spec, len(args)+1) {
+		switch arg := args[pos-1].(type) {
+		case []string:
+			// keys of the typed api like Del
+			keys = append(keys, arg...)
+		default:
+			keys = append(keys, formatArg(arg))
+		}
+	}
+	return keys
+}
+
+// checkACL checks the command of r with user, the commands of an exec are
+// checked one by one instead of exec itself
+func (s *MemCache) checkACL(user string, cmd *command, r IResult) error {
+	cmds, ok := execCommands(r)
+	if !ok {
+		return s.acl.check(user, cmd.info(), commandKeys(cmd, r))
+	}
+	for _, r := range cmds {
+		cmd := s.lookupCommand(r.Name())
+		if err := s.acl.check(user, cmd.info(), commandKeys(cmd, r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ACLCheck reports whether user can run the command with keys, commands of
+// the MemCache are checked with the user of WithClientInfo, ACLCheck is for
+// the commands of a server
+func (s *MemCache) ACLCheck(user string, info CommandInfo, keys ...string) error {
+	return s.acl.check(user, info, keys)
+}
+
+// ACLCheckChannel reports whether user can publish or subscribe to channel,
+// or subscribe to the pattern channel when isPattern is true
+func (s *MemCache) ACLCheckChannel(user, channel string, isPattern bool) error {
+	return s.acl.checkChannel(user, channel, isPattern)
+}
+
+// ACLAuth checks the password of user, any password is accepted by a nopass
+// user, ErrWrongPass if the password is wrong or the user is disabled
+func (s *MemCache) ACLAuth(user, password string) *BoolResult {
+	cmd := NewBoolResult("auth", user)
+	s.acl.mu.RLock()
+	defer s.acl.mu.RUnlock()
+	u := s.acl.users[user]
+	if u == nil || !u.enabled {
+		cmd.SetError(ErrWrongPass)
+		return cmd
+	}
+	if _, ok := u.passwords[hashPassword(password)]; !ok && !u.nopass {
+		cmd.SetError(ErrWrongPass)
+		return cmd
+	}
+	cmd.SetVal(true)
+	return cmd
+}
+
+// ACLSetUser creates or modifies user with rules like ACL SETUSER, nothing
+// is changed if a rule is invalid
+func (s *MemCache) ACLSetUser(user string, rules ...string) *BoolResult {
+	cmd := NewBoolResult("acl", "setuser", user)
+	if user == "" {
+		cmd.SetError(fmt.Errorf("%w: user name can't be empty", ErrInvalidArgument))
+		return cmd
+	}
+	s.acl.mu.Lock()
+	defer s.acl.mu.Unlock()
+	u := newACLUser(user)
+	if old := s.acl.users[user]; old != nil {
+		u = old.clone()
+	}
+	for _, rule := range rules {
+		if err := u.apply(rule, s.knownCategory); err != nil {
+			cmd.SetError(err)
+			return cmd
+		}
+	}
+	s.acl.users[user] = u
+	cmd.SetVal(true)
+	return cmd
+}
+
+// ACLGetUser returns the rules of user, Nil if it doesn't exist
+func (s *MemCache) ACLGetUser(user string) *ACLUserResult {
+	cmd := NewACLUserResult("acl", "getuser", user)
+	s.acl.mu.RLock()
+	defer s.acl.mu.RUnlock()
+	u := s.acl.users[user]
+	if u == nil {
+		cmd.SetError(Nil)
+		return cmd
+	}
+	cmd.SetVal(u.info())
+	return cmd
+}
+
+// ACLDelUser deletes users and returns the count of deleted users, the
+// default user can't be deleted
+func (s *MemCache) ACLDelUser(users ...string) *IntResult {
+	cmd := NewIntResult("acl", "deluser", users)
+	s.acl.mu.Lock()
+	defer s.acl.mu.Unlock()
+	deleted := 0
+	for _, user := range users {
+		if user == DefaultUser {
+			cmd.SetError(fmt.Errorf("%w: the '%s' user cannot be removed", ErrInvalidArgument, DefaultUser))
+			return cmd
+		}
+	}
+	for _, user := range users {
+		if _, ok := s.acl.users[user]; ok {
+			delete(s.acl.users, user)
+			deleted++
+		}
+	}
+	cmd.SetVal(deleted)
+	return cmd
+}
+
+// ACLUsers returns the names of the users sorted
+func (s *MemCache) ACLUsers() *StringsResult {
+	cmd := NewStringsResult("acl", "users")
+	s.acl.mu.RLock()
+	defer s.acl.mu.RUnlock()
+	names := make([]string, 0, len(s.acl.users))
+	for name := range s.acl.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmd.SetVal(names)
+	return cmd
+}
+
+// ACLList returns the users like ACL LIST, "user <name> <rules>"
+func (s *MemCache) ACLList() *StringsResult {
+	cmd := NewStringsResult("acl", "list")
+	s.acl.mu.RLock()
+	defer s.acl.mu.RUnlock()
+	names := make([]string, 0, len(s.acl.users))
+	for name := range s.acl.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = "user " + name + " " + s.acl.users[name].info().Rules()
+	}
+	cmd.SetVal(list)
+	return cmd
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -16,6 +17,9 @@ const (
 )
 
 type MemCacheDB struct {
+	// index of this db, and the cache it belongs to
+	id   int
+	core *core
 	//all keys
 	keys map[string]ValueType
 	ttl  map[string]time.Time
@@ -23,44 +27,102 @@ type MemCacheDB struct {
 	s  str
 	hm hmap
 	hs hset
-	// internal function
-	name2func map[string]Cmd
+	// memcached flags and cas of string values
+	smeta  map[string]stringMeta
+	casSeq uint64
 	// storage limit
 	count int
 	msize int
+	// estimated memory of every key, and of the whole db
+	mem       map[string]int64
+	used      int64
+	maxMemory int64
+	// eviction when a limit is reached
+	access  map[string]keyAccess
+	policy  EvictionPolicy
+	samples int
+	evicted int64
+	// keys deleted because of ttl, and the ones by the ttl goroutine
+	expired       int64
+	activeExpired int64
+	// lfu counter config
+	lfuLogFactor int
+	lfuDecayTime int
 }
 
-type MemCache struct {
-	l  sync.Mutex
-	db *MemCacheDB
+// core is shared by every MemCache handle of the same cache
+type core struct {
+	l   sync.Mutex
+	dbs []*MemCacheDB
+	// closed by Close, stops the ttl goroutine
+	closed bool
+	stop   chan struct{}
+	// pub/sub has its own lock
+	ps *pubsub
+	// keyspace notifications queued by the commands
+	notifyFlags int
+	onEvent     func(event KeyspaceEvent)
+	events      []KeyspaceEvent
+	// statistics of the commands and the ttl goroutine
+	stats   coreStats
+	slowLog *slowLog
+	// monitors receive every command
+	monitors          map[*Monitor]struct{}
+	monitorBufferSize int
+	// hooks of CacheConf, never modified
+	hooks []Hook
+	acl   *acl
+	// commands of RegisterCommand, the built-in ones are shared by every cache
+	commandsMu sync.RWMutex
+	commands   map[string]*command
 }
 
-type Cmd func(result IResult)
+// MemCache is a handle bound to one database, see DB
+type MemCache struct {
+	*core
+	index int
+}
 
 func (db *MemCacheDB) doBeforeProcess(key string, cmdType ValueType) error {
-	if db.count >= db.msize && db.keys[key] == DEFAULT {
-		return fmt.Errorf("keys count limit: %d", db.msize)
+	err := db.expireIfNeeded(key)
+	if err != nil {
+		return err
 	}
 	valueType := db.keys[key]
 	if cmdType != DEFAULT && valueType != DEFAULT && valueType != cmdType {
-		return fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return ErrWrongType
+	}
+	if valueType != DEFAULT {
+		db.touch(key)
 	}
-	//if ttl exist, and NOW > ttl, lazy del key
+	return nil
+}
+
+// if ttl exist, and NOW > ttl, lazy del key
+func (db *MemCacheDB) expireIfNeeded(key string) error {
 	expireTime := db.ttl[key]
 	if !expireTime.IsZero() && time.Now().After(expireTime) {
 		_, err := db.delKey(key, true)
 		if err != nil {
 			return err
 		}
+		db.expired++
+		db.notify(notifyExpired, "expired", key)
 	}
 	return nil
 }
 
-// valueType param can't be DEFAULT
+// valueType param can't be DEFAULT, when keys count limit is reached
+// a key is evicted first, error if nothing can be evicted
 func (db *MemCacheDB) addKey(key string, valueType ValueType) (bool, error) {
 	// key don't exist before addKey
 	if db.keys[key] == DEFAULT {
+		if db.count >= db.msize && !db.evict() {
+			return false, &LimitError{Name: "keys count", Limit: int64(db.msize)}
+		}
 		db.count++
+		db.incrMem(key, int64(len(key))+keyOverhead+valueOverhead(valueType))
+		db.touch(key)
 	}
 	db.keys[key] = valueType
 	return true, nil
@@ -71,12 +133,16 @@ func (db *MemCacheDB) delKey(key string, ttl bool) (bool, error) {
 	if db.keys[key] != DEFAULT {
 		db.count--
 		delete(db.keys, key)
+		db.used -= db.mem[key]
+		delete(db.mem, key)
+		delete(db.access, key)
 	}
 	if ttl {
 		delete(db.ttl, key)
 	}
 	if valueType == STRING && db.s[key] != nil {
 		delete(db.s, key)
+		delete(db.smeta, key)
 		return true, nil
 	} else if valueType == HASH && db.hm[key] != nil {
 		delete(db.hm, key)
@@ -88,76 +154,397 @@ func (db *MemCacheDB) delKey(key string, ttl bool) (bool, error) {
 	return false, nil
 }
 
-func (db *MemCacheDB) register(cmd string, f Cmd) error {
-	db.name2func[cmd] = f
-	return nil
+func newMemCacheDB(c *core, id int, conf *CacheConf) *MemCacheDB {
+	db := &MemCacheDB{
+		id:        id,
+		core:      c,
+		keys:      make(map[string]ValueType),
+		ttl:       make(map[string]time.Time),
+		s:         initStr(),
+		hm:        initHmap(),
+		hs:        initHset(),
+		smeta:     make(map[string]stringMeta),
+		msize:     conf.MaxSize,
+		count:     0,
+		mem:       make(map[string]int64),
+		maxMemory: conf.MaxMemoryBytes,
+		access:    make(map[string]keyAccess),
+		policy:    conf.MaxMemoryPolicy,
+		samples:   conf.MaxMemorySamples,
+	}
+	if db.samples <= 0 {
+		db.samples = DefaultMaxMemorySamples
+	}
+	db.lfuLogFactor = conf.LfuLogFactor
+	if db.lfuLogFactor <= 0 {
+		db.lfuLogFactor = DefaultLfuLogFactor
+	}
+	db.lfuDecayTime = conf.LfuDecayTime
+	if db.lfuDecayTime == 0 {
+		db.lfuDecayTime = DefaultLfuDecayTime
+	}
+	return db
 }
 
 func NewMemCache(conf *CacheConf) (*MemCache, error) {
-	s := &MemCache{
-		l: sync.Mutex{},
-		db: &MemCacheDB{
-			keys:      make(map[string]ValueType),
-			ttl:       make(map[string]time.Time),
-			s:         initStr(),
-			hm:        initHmap(),
-			hs:        initHset(),
-			name2func: map[string]Cmd{},
-			msize:     conf.MaxSize,
-			count:     0,
+	err := checkEvictionPolicy(conf.MaxMemoryPolicy)
+	if err != nil {
+		return nil, err
+	}
+	err = checkSlowSubscriberPolicy(conf.PubSubSlowPolicy)
+	if err != nil {
+		return nil, err
+	}
+	notifyFlags, err := parseNotifyKeyspaceEvents(conf.NotifyKeyspaceEvents)
+	if err != nil {
+		return nil, err
+	}
+	databases := conf.Databases
+	if databases <= 0 {
+		databases = DefaultDatabases
+	}
+	c := &core{
+		l:    sync.Mutex{},
+		dbs:  make([]*MemCacheDB, databases),
+		stop: make(chan struct{}),
+		ps:   newPubSub(conf),
+
+		notifyFlags: notifyFlags,
+		onEvent:     conf.OnKeyspaceEvent,
+
+		stats: coreStats{
+			started:  time.Now(),
+			commands: make(map[string]*CommandStats),
+			errors:   make(map[string]int64),
 		},
+		slowLog: newSlowLog(conf),
+
+		monitors:          make(map[*Monitor]struct{}),
+		monitorBufferSize: conf.MonitorBufferSize,
+
+		hooks: append([]Hook(nil), conf.Hooks...),
+		acl:   newACL(),
+
+		commands: make(map[string]*command),
+	}
+	if conf.Tracer != nil {
+		c.hooks = append([]Hook{&tracingHook{tracer: conf.Tracer, hashKeys: conf.TraceHashKeys, core: c}}, c.hooks...)
+	}
+	if c.monitorBufferSize <= 0 {
+		c.monitorBufferSize = DefaultMonitorBufferSize
+	}
+	for i := range c.dbs {
+		c.dbs[i] = newMemCacheDB(c, i, conf)
 	}
-	// add a command init function when add a new data structure
-	commandString(s.db)
-	commandHashMap(s.db)
-	commandHashSet(s.db)
-	// ttl policy
+	s := &MemCache{core: c, index: 0}
+	// ttl policy, every db is swept in turn
 	go func() {
 		ttlPeriodMillSecond := conf.TtlPeriodMillSecond
 		if ttlPeriodMillSecond <= 0 {
 			ttlPeriodMillSecond = 100 //default 100ms
 		}
+		ticker := time.NewTicker(time.Duration(ttlPeriodMillSecond) * time.Millisecond)
+		defer ticker.Stop()
 		for {
-			s.l.Lock()
-			volatileRange(s.db)
-			s.l.Unlock()
-			time.Sleep(time.Duration(ttlPeriodMillSecond) * time.Millisecond)
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+			c.l.Lock()
+			start := time.Now()
+			for _, db := range c.dbs {
+				volatileRange(db)
+			}
+			c.stats.recordExpireCycle(time.Since(start))
+			events := c.takeEvents()
+			c.l.Unlock()
+			c.deliverEvents(events)
 		}
 	}()
 
 	return s, nil
 }
 
-func (s *MemCache) doWithTransaction(r IResult) {
+// DB returns a handle of the same cache bound to database index,
+// handles share the lock and the data of all databases.
+func (s *MemCache) DB(index int) (*MemCache, error) {
+	if index < 0 || index >= len(s.dbs) {
+		return nil, fmt.Errorf("%w: DB index is out of range", ErrInvalidArgument)
+	}
+	return &MemCache{core: s.core, index: index}, nil
+}
+
+// Stats returns the statistics of all databases, the keyspace statistics
+// walk the ttl of every database to compute the average ttl
+func (s *MemCache) Stats() *Stats {
+	s.l.Lock()
+	defer s.l.Unlock()
+	now := time.Now()
+	stats := &Stats{
+		Uptime:              now.Sub(s.stats.started),
+		ExpireCycles:        s.stats.expireCycles,
+		ExpireCycleTime:     s.stats.expireCycleTime,
+		LastExpireCycleTime: s.stats.lastExpireCycleTime,
+		Commands:            make(map[string]CommandStats, len(s.stats.commands)),
+		Errors:              make(map[string]int64, len(s.stats.errors)),
+	}
+	for typ, n := range s.stats.errors {
+		stats.Errors[typ] = n
+	}
+	for name, cs := range s.stats.commands {
+		stats.Commands[name] = *cs
+		stats.CommandsProcessed += cs.Calls
+		stats.KeyspaceHits += cs.Hits
+		stats.KeyspaceMisses += cs.Misses
+		stats.RejectedByLimit += cs.RejectedByLimit
+	}
+	for _, db := range s.dbs {
+		stats.EvictedKeys += db.evicted
+		stats.ExpiredKeys += db.expired
+		stats.ActiveExpiredKeys += db.activeExpired
+		stats.UsedMemory += db.used
+		stats.MaxMemory = db.maxMemory
+		stats.MaxMemoryPolicy = db.policy
+		if db.count > 0 {
+			stats.Keyspace = append(stats.Keyspace, db.keyspaceStats(now))
+		}
+	}
+	return stats
+}
+
+// Info returns the statistics as text like redis INFO, sections are server,
+// memory, persistence, stats, commandstats and keyspace, or all and default
+func (s *MemCache) Info(sections ...string) *StringResult {
+	cmd := NewStringResult("info", sections)
+	cmd.SetVal(formatInfo(s.Stats(), sections))
+	return cmd
+}
+
+// Index returns the database index the handle is bound to
+func (s *MemCache) Index() int {
+	return s.index
+}
+
+// Close stops the ttl goroutine and closes the subscribers, commands after
+// Close return ErrClosed
+func (s *MemCache) Close() error {
 	s.l.Lock()
 	defer s.l.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.closed = true
+	close(s.stop)
+	s.ps.close()
+	for m := range s.monitors {
+		s.closeMonitor(m)
+	}
+	return nil
+}
+
+// doWithTransaction runs the command of r with the data lock held, the
+// keyspace events of the command are delivered after the lock is released
+func (s *MemCache) doWithTransaction(r IResult) {
+	s.doWithContext(context.Background(), r)
+}
+
+// doWithContext is doWithTransaction with ctx for the hooks, and the client
+// of ctx for the slow log and the monitors. A panic of a hook or of the
+// dispatch around the command is recovered into the error of r like a panic
+// of the command
+func (s *MemCache) doWithContext(ctx context.Context, r IResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			err := &PanicError{Name: r.Name(), Value: p}
+			r.SetError(err)
+			s.l.Lock()
+			s.stats.recordError(err)
+			s.l.Unlock()
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		r.SetError(err)
+		return
+	}
+	if len(s.hooks) > 0 {
+		s.processWithHooks(ctx, r, s.processWithLock)
+		return
+	}
+	s.processWithLock(ctx, r)
+}
+
+// processWithLock runs the command of r with the data lock held and delivers
+// the keyspace events after the lock is released, the lock is released even
+// if the dispatch panics
+func (s *MemCache) processWithLock(ctx context.Context, r IResult) {
+	var events []KeyspaceEvent
+	defer func() {
+		s.deliverEvents(events)
+	}()
+	start := time.Now()
+	s.l.Lock()
+	defer func() {
+		events = s.takeEvents()
+		s.l.Unlock()
+	}()
+	s.process(ctx, r, time.Since(start))
+}
+
+// process runs the command of r, a panic of the command is recovered into
+// the error of r
+func (s *MemCache) process(ctx context.Context, r IResult, wait time.Duration) {
+	if s.closed {
+		r.SetError(ErrClosed)
+		s.stats.recordError(ErrClosed)
+		return
+	}
 	cmdName := r.Name()
-	s.db.name2func[cmdName](r)
+	cmd := s.lookupCommand(cmdName)
+	if cmd == nil {
+		r.SetError(unknownCommand(cmdName))
+		s.stats.recordError(ErrUnknownCommand)
+		return
+	}
+	if client, ok := ClientInfoFromContext(ctx); ok && client.User != "" {
+		err := s.checkACL(client.User, cmd, r)
+		if err != nil {
+			r.SetError(err)
+			s.stats.recordError(err)
+			return
+		}
+	}
+	s.feedMonitors(ctx, s.index, r)
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			r.SetError(&PanicError{Name: cmdName, Value: p})
+		}
+		exec := time.Since(start)
+		s.stats.record(cmd, r, wait, exec)
+		s.slowLog.add(ctx, s.index, r, exec)
+	}()
+	cmd.fn(s.dbs[s.index], r)
 }
 
-//string api
-//********************************************************************
+// string api
+// ********************************************************************
 func (s *MemCache) Set(key string, value []byte) *BoolResult {
+	return s.SetContext(context.Background(), key, value)
+}
+
+// SetContext is Set with ctx for the hooks and the tracer
+func (s *MemCache) SetContext(ctx context.Context, key string, value []byte) *BoolResult {
 	//Todo: check param.
 	cmd := NewBoolResult("set", key, value)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// SetEXContext is SetContext with a ttl of seconds set in the same command,
+// the ttl is not set when the write fails
+func (s *MemCache) SetEXContext(ctx context.Context, key string, value []byte, seconds int) *BoolResult {
+	cmd := NewBoolResult("set", key, value)
+	s.writeEX(ctx, cmd, key, seconds)
 	return cmd
 }
 
 func (s *MemCache) Get(key string) *BytesResult {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext is Get with ctx for the hooks and the tracer
+func (s *MemCache) GetContext(ctx context.Context, key string) *BytesResult {
 	cmd := NewBytesResult("get", key)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
 	return cmd
 }
 
 func (s *MemCache) Del(keys ...string) *IntResult {
+	return s.DelContext(context.Background(), keys...)
+}
+
+// DelContext is Del with ctx for the hooks and the tracer
+func (s *MemCache) DelContext(ctx context.Context, keys ...string) *IntResult {
 	cmd := NewIntResult("del", keys)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
 	return cmd
 }
 
 func (s *MemCache) Expire(key string, seconds int) *IntResult {
+	return s.ExpireContext(context.Background(), key, seconds)
+}
+
+// ExpireContext is Expire with ctx for the hooks and the tracer
+func (s *MemCache) ExpireContext(ctx context.Context, key string, seconds int) *IntResult {
 	cmd := NewIntResult("expire", key, seconds)
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// MemoryUsage returns the estimated bytes used by key, Nil if key not exist
+func (s *MemCache) MemoryUsage(key string) *IntResult {
+	cmd := NewIntResult("memory", "usage", key)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// ObjectFreq returns the logarithmic access frequency counter of key
+func (s *MemCache) ObjectFreq(key string) *IntResult {
+	cmd := NewIntResult("object", "freq", key)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// ObjectIdleTime returns the seconds since key was last used
+func (s *MemCache) ObjectIdleTime(key string) *IntResult {
+	cmd := NewIntResult("object", "idletime", key)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+//database api
+//********************************************************************
+
+// Move key from the current database to database db
+func (s *MemCache) Move(key string, db int) *IntResult {
+	return s.MoveContext(context.Background(), key, db)
+}
+
+// MoveContext is Move with ctx for the hooks and the tracer
+func (s *MemCache) MoveContext(ctx context.Context, key string, db int) *IntResult {
+	cmd := NewIntResult("move", key, db)
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// FlushDB deletes all keys of the current database
+func (s *MemCache) FlushDB() *BoolResult {
+	return s.FlushDBContext(context.Background())
+}
+
+// FlushDBContext is FlushDB with ctx for the hooks and the tracer
+func (s *MemCache) FlushDBContext(ctx context.Context) *BoolResult {
+	cmd := NewBoolResult("flushdb")
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// DBSize returns the keys count of the current database
+func (s *MemCache) DBSize() *IntResult {
+	return s.DBSizeContext(context.Background())
+}
+
+// DBSizeContext is DBSize with ctx for the hooks and the tracer
+func (s *MemCache) DBSizeContext(ctx context.Context) *IntResult {
+	cmd := NewIntResult("dbsize")
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// SwapDB swaps the data of two databases, handles bound to index1 see the data of index2 and vice versa
+func (s *MemCache) SwapDB(index1, index2 int) *BoolResult {
+	cmd := NewBoolResult("swapdb", index1, index2)
 	s.doWithTransaction(cmd)
 	return cmd
 }
@@ -166,20 +553,43 @@ func (s *MemCache) Expire(key string, seconds int) *IntResult {
 //********************************************************************
 
 func (s *MemCache) HSet(key, field string, value []byte) *IntResult {
+	return s.HSetContext(context.Background(), key, field, value)
+}
+
+// HSetContext is HSet with ctx for the hooks and the tracer
+func (s *MemCache) HSetContext(ctx context.Context, key, field string, value []byte) *IntResult {
 	cmd := NewIntResult("hset", key, field, value)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// HSetEXContext is HSetContext with a ttl of seconds on key set in the same
+// command, the ttl is not set when the write fails
+func (s *MemCache) HSetEXContext(ctx context.Context, key, field string, value []byte, seconds int) *IntResult {
+	cmd := NewIntResult("hset", key, field, value)
+	s.writeEX(ctx, cmd, key, seconds)
 	return cmd
 }
 
 func (s *MemCache) HGet(key, field string) *BytesResult {
+	return s.HGetContext(context.Background(), key, field)
+}
+
+// HGetContext is HGet with ctx for the hooks and the tracer
+func (s *MemCache) HGetContext(ctx context.Context, key, field string) *BytesResult {
 	cmd := NewBytesResult("hget", key, field)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
 	return cmd
 }
 
 func (s *MemCache) HDel(key string, field ...string) *IntResult {
+	return s.HDelContext(context.Background(), key, field...)
+}
+
+// HDelContext is HDel with ctx for the hooks and the tracer
+func (s *MemCache) HDelContext(ctx context.Context, key string, field ...string) *IntResult {
 	cmd := NewIntResult("hdel", key, field)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
 	return cmd
 }
 
@@ -187,13 +597,148 @@ func (s *MemCache) HDel(key string, field ...string) *IntResult {
 //********************************************************************
 
 func (s *MemCache) SAdd(key string, members ...string) *IntResult {
+	return s.SAddContext(context.Background(), key, members...)
+}
+
+// SAddContext is SAdd with ctx for the hooks and the tracer
+func (s *MemCache) SAddContext(ctx context.Context, key string, members ...string) *IntResult {
 	cmd := NewIntResult("sadd", key, members)
-	s.doWithTransaction(cmd)
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+// SAddEXContext is SAddContext with a ttl of seconds set in the same
+// command, the ttl is not set when the write fails
+func (s *MemCache) SAddEXContext(ctx context.Context, key string, seconds int, members ...string) *IntResult {
+	cmd := NewIntResult("sadd", key, members)
+	s.writeEX(ctx, cmd, key, seconds)
 	return cmd
 }
 
 func (s *MemCache) SIsMember(key, member string) *IntResult {
+	return s.SIsMemberContext(context.Background(), key, member)
+}
+
+// SIsMemberContext is SIsMember with ctx for the hooks and the tracer
+func (s *MemCache) SIsMemberContext(ctx context.Context, key, member string) *IntResult {
 	cmd := NewIntResult("sismember", key, member)
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
+
+//memcached api, string values with flags and cas
+//********************************************************************
+
+// MCGet returns items of the string keys exist, used by get and gets
+func (s *MemCache) MCGet(keys ...string) *ItemsResult {
+	cmd := NewItemsResult("mcget", keys)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// MCStore stores item by mode, item.Cas is only used by StoreCas,
+// zero expireAt means never expire, returns Stored, NotStored, Exists or NotFound
+func (s *MemCache) MCStore(mode StoreMode, item *Item, expireAt time.Time) *IntResult {
+	cmd := NewIntResult("mcstore", mode, item, expireAt)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// MCIncr increments the decimal value of key, returns the new value, Nil if key not exist
+func (s *MemCache) MCIncr(key string, delta uint64) *BytesResult {
+	cmd := NewBytesResult("mcincr", key, delta, false)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// MCDecr decrements the decimal value of key, returns the new value, Nil if key not exist
+func (s *MemCache) MCDecr(key string, delta uint64) *BytesResult {
+	cmd := NewBytesResult("mcincr", key, delta, true)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// MCTouch updates the expire time of key, returns 1 if key exist else 0,
+// keys of other types than string are not touched
+func (s *MemCache) MCTouch(key string, expireAt time.Time) *IntResult {
+	cmd := NewIntResult("mctouch", key, expireAt)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// MCDelete deletes key if it is a string, returns 1 if deleted else 0
+func (s *MemCache) MCDelete(key string) *IntResult {
+	cmd := NewIntResult("mcdelete", key)
+	s.doWithTransaction(cmd)
+	return cmd
+}
+
+// MCFlush deletes all string keys and keeps the keys of other types,
+// returns the count of deleted keys
+func (s *MemCache) MCFlush() *IntResult {
+	cmd := NewIntResult("mcflush")
 	s.doWithTransaction(cmd)
 	return cmd
 }
+
+//pub/sub api, independent of the data lock and the database
+//********************************************************************
+
+// Publish sends message to the subscribers of channel and of the patterns
+// matching channel, returns the count of subscribers received it
+func (s *MemCache) Publish(channel string, message []byte) *IntResult {
+	cmd := NewIntResult("publish", channel, message)
+	n, err := s.ps.publish(channel, message)
+	if err != nil {
+		cmd.SetError(err)
+		return cmd
+	}
+	cmd.SetVal(n)
+	return cmd
+}
+
+func (s *MemCache) newSubscriber() *PubSub {
+	p := s.ps.newSubscriber()
+	s.ps.mu.Lock()
+	if s.ps.closed {
+		s.ps.closeSubscriber(p, ErrClosed)
+	}
+	s.ps.mu.Unlock()
+	return p
+}
+
+// Subscribe returns a subscriber of channels, messages are received from PubSub.Channel
+func (s *MemCache) Subscribe(channels ...string) *PubSub {
+	p := s.newSubscriber()
+	p.Subscribe(channels...)
+	return p
+}
+
+// PSubscribe returns a subscriber of glob patterns like news.*
+func (s *MemCache) PSubscribe(patterns ...string) *PubSub {
+	p := s.newSubscriber()
+	p.PSubscribe(patterns...)
+	return p
+}
+
+// PubSubChannels returns the channels having subscribers and matching pattern,
+// all channels if pattern is empty
+func (s *MemCache) PubSubChannels(pattern string) *StringsResult {
+	cmd := NewStringsResult("pubsub", "channels", pattern)
+	cmd.SetVal(s.ps.channelNames(pattern))
+	return cmd
+}
+
+// PubSubNumSub returns the count of subscribers of every channel, patterns are not counted
+func (s *MemCache) PubSubNumSub(channels ...string) *IntMapResult {
+	cmd := NewIntMapResult("pubsub", "numsub", channels)
+	cmd.SetVal(s.ps.numSub(channels))
+	return cmd
+}
+
+// PubSubNumPat returns the count of subscribed patterns
+func (s *MemCache) PubSubNumPat() *IntResult {
+	cmd := NewIntResult("pubsub", "numpat")
+	cmd.SetVal(s.ps.numPat())
+	return cmd
+}
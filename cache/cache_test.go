@@ -1,7 +1,10 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,16 +27,16 @@ func TestGetNil(t *testing.T) {
 		t.Fatal(err.Error())
 	}
 	_, err = cache.Get("test1").Result()
-	if err != nil {
-		t.Fatal(err.Error())
+	if err != Nil {
+		t.Fatal("get not exist key should return Nil")
 	}
 	_, err = cache.Del("test1").Result()
 	if err != nil {
 		t.Fatal(err.Error())
 	}
 	_, err = cache.HGet("test1", "111").Result()
-	if err != nil {
-		t.Fatal(err.Error())
+	if err != Nil {
+		t.Fatal("hget not exist key should return Nil")
 	}
 	_, err = cache.HDel("test1").Result()
 	if err != nil {
@@ -85,7 +88,7 @@ func TestDelString(t *testing.T) {
 	}
 
 	res, err = cache.Get("test3").Result()
-	if string(res) != "" || err != nil {
+	if res != nil || err != Nil {
 		t.Fatal("get error")
 	}
 }
@@ -113,7 +116,7 @@ func TestExpire(t *testing.T) {
 	}
 	time.Sleep(time.Duration(2) * time.Second)
 	resA, err := cache.Get("test2").Result()
-	if string(resA) != "" || err != nil {
+	if resA != nil || err != Nil {
 		t.Fatal("expire func error")
 	}
 }
@@ -217,12 +220,12 @@ func TestDelMulti(t *testing.T) {
 	}
 
 	getRes, err := cache.Get("test2").Result()
-	if string(getRes) != "" || err != nil {
+	if string(getRes) != "" || err != Nil {
 		t.Fatal("get error")
 	}
 	hgetRes, err := cache.HGet("key1", "field1").Result()
-	if err != nil {
-		t.Fatal(err.Error())
+	if err != Nil {
+		t.Fatal("hget error")
 	}
 	if string(hgetRes) != "" {
 		t.Fatal("hget result error")
@@ -295,6 +298,1209 @@ func TestSIsMember(t *testing.T) {
 	t.Log("Sadd: res0=", res0, ", res1=", res1, ", res2=", res2, ", res3=", res3, ", res4=", res4)
 }
 
+func TestSelectDB(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, Databases: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	db1, err := cache.DB(1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = cache.Set("key1", []byte("0")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = db1.Set("key1", []byte("1")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res0, err := cache.Get("key1").Result()
+	if string(res0) != "0" || err != nil {
+		t.Fatal("db0 get error")
+	}
+	res1, err := db1.Get("key1").Result()
+	if string(res1) != "1" || err != nil {
+		t.Fatal("db1 get error")
+	}
+	_, err = cache.DB(2)
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+}
+
+func TestSwapDB(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, Databases: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	db1, _ := cache.DB(1)
+	_, err = cache.Set("key1", []byte("0")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ok, err := cache.SwapDB(0, 1).Result()
+	if !ok || err != nil {
+		t.Fatal("swapdb error")
+	}
+	res0, err := cache.Get("key1").Result()
+	if res0 != nil || err != Nil {
+		t.Fatal("db0 should be empty after swapdb")
+	}
+	res1, err := db1.Get("key1").Result()
+	if string(res1) != "0" || err != nil {
+		t.Fatal("db1 get error after swapdb")
+	}
+	_, err = cache.SwapDB(0, 2).Result()
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+}
+
+func TestMove(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, Databases: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	db1, _ := cache.DB(1)
+	_, err = cache.HSet("key1", "field1", []byte("100")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Expire("key1", 100)
+	res, err := cache.Move("key1", 1).Result()
+	if res != 1 || err != nil {
+		t.Fatal("move result error")
+	}
+	res, err = cache.Move("key1", 1).Result()
+	if res != 0 || err != nil {
+		t.Fatal("move not exist result error")
+	}
+	hgetRes, err := db1.HGet("key1", "field1").Result()
+	if string(hgetRes) != "100" || err != nil {
+		t.Fatal("hget moved key error")
+	}
+	if db1.dbs[1].ttl["key1"].IsZero() {
+		t.Fatal("ttl should be moved")
+	}
+	_, err = cache.Set("key1", []byte("0")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res, err = cache.Move("key1", 1).Result()
+	if res != 0 || err != nil {
+		t.Fatal("move exist result error")
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res0, err := cache.MemoryUsage("key1").Result()
+	if res0 != 0 || err != Nil {
+		t.Fatal("res0 error")
+	}
+	cache.HSet("key1", "field1", []byte("100"))
+	res1, err := cache.MemoryUsage("key1").Result()
+	if res1 <= 0 || err != nil {
+		t.Fatal("res1 error")
+	}
+	cache.HSet("key1", "field2", []byte("200"))
+	res2, err := cache.MemoryUsage("key1").Result()
+	if res2 <= res1 || err != nil {
+		t.Fatal("res2 error")
+	}
+	cache.HDel("key1", "field2")
+	res3, err := cache.MemoryUsage("key1").Result()
+	if res3 != res1 || err != nil {
+		t.Fatal("res3 error")
+	}
+	// memory usage doesn't touch the key
+	access := cache.dbs[0].access["key1"]
+	access.lru = access.lru.Add(-time.Minute)
+	cache.dbs[0].access["key1"] = access
+	cache.MemoryUsage("key1")
+	if cache.dbs[0].access["key1"] != access {
+		t.Fatal("memory usage should not touch the key")
+	}
+	cache.Del("key1")
+	if cache.dbs[0].used != 0 {
+		t.Fatal("used memory should be 0 after del, used=", cache.dbs[0].used)
+	}
+	t.Log("MemoryUsage: res1=", res1, ", res2=", res2)
+}
+
+func TestMemoryLimit(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, MaxMemoryBytes: 1024})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = cache.Set("test1", make([]byte, 1024)).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// should have error
+	_, err = cache.SAdd("test2", "out of limit").Result()
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+	cache.Del("test1")
+	_, err = cache.SAdd("test2", "in limit").Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	_, err := NewMemCache(&CacheConf{MaxSize: 2, MaxMemoryPolicy: "unknown"})
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+	cache, err := NewMemCache(&CacheConf{MaxSize: 2, MaxMemoryPolicy: AllKeysLRU})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	time.Sleep(time.Millisecond)
+	cache.Set("test2", []byte("2"))
+	time.Sleep(time.Millisecond)
+	cache.Get("test1")
+	_, err = cache.Set("test3", []byte("3")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res, _ := cache.Get("test2").Result()
+	if string(res) != "" {
+		t.Fatal("least recently used key should be evicted")
+	}
+	res, _ = cache.Get("test1").Result()
+	if string(res) != "1" {
+		t.Fatal("recently used key should not be evicted")
+	}
+	if cache.Stats().EvictedKeys != 1 {
+		t.Fatal("evicted keys stats error")
+	}
+}
+
+func TestEvictionVolatile(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 2, MaxMemoryPolicy: VolatileTTL})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	cache.Set("test2", []byte("2"))
+	// no key with ttl, nothing can be evicted
+	_, err = cache.Set("test3", []byte("3")).Result()
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+	cache.Expire("test1", 100)
+	cache.Expire("test2", 10)
+	_, err = cache.Set("test3", []byte("3")).Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res, _ := cache.Get("test2").Result()
+	if string(res) != "" {
+		t.Fatal("key with the nearest ttl should be evicted")
+	}
+}
+
+func TestEvictionMemory(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, MaxMemoryBytes: 1024, MaxMemoryPolicy: AllKeysLFU})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", make([]byte, 1024))
+	_, err = cache.SAdd("test2", "member").Result()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	res, _ := cache.Get("test1").Result()
+	if len(res) != 0 {
+		t.Fatal("key should be evicted")
+	}
+}
+
+func TestObject(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = cache.ObjectFreq("key1").Result()
+	if !errors.Is(err, ErrNoSuchKey) {
+		t.Fatal("object not exist key should return ErrNoSuchKey")
+	}
+	cache.Set("key1", []byte("1"))
+	res0, err := cache.ObjectFreq("key1").Result()
+	if err != nil || res0 != lfuInitVal {
+		t.Fatal("res0 error")
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Get("key1")
+	}
+	res1, err := cache.ObjectFreq("key1").Result()
+	if err != nil || res1 <= res0 || res1 > 255 {
+		t.Fatal("res1 error")
+	}
+	// OBJECT doesn't touch the key
+	res2, _ := cache.ObjectFreq("key1").Result()
+	if res2 != res1 {
+		t.Fatal("res2 error")
+	}
+	idle, err := cache.ObjectIdleTime("key1").Result()
+	if err != nil || idle != 0 {
+		t.Fatal("idletime error")
+	}
+	// decay one period
+	access := cache.dbs[0].access["key1"]
+	access.ldt = access.ldt.Add(-time.Minute)
+	access.lru = access.lru.Add(-time.Minute)
+	cache.dbs[0].access["key1"] = access
+	res3, _ := cache.ObjectFreq("key1").Result()
+	if res3 != res1-1 {
+		t.Fatal("res3 error")
+	}
+	idle, _ = cache.ObjectIdleTime("key1").Result()
+	if idle != 60 {
+		t.Fatal("idletime after a minute error")
+	}
+	t.Log("ObjectFreq: res0=", res0, ", res1=", res1, ", res3=", res3)
+}
+
+func TestErrors(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	_, err = cache.HGet("test1", "field1").Result()
+	if !errors.Is(err, ErrWrongType) {
+		t.Fatal("should be ErrWrongType, err=", err)
+	}
+	_, err = cache.SAdd("test2", "member").Result()
+	var limitErr *LimitError
+	if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &limitErr) || limitErr.Limit != 1 {
+		t.Fatal("should be ErrLimitExceeded, err=", err)
+	}
+	cmd := NewBytesResult("get")
+	cache.doWithTransaction(cmd)
+	if !errors.Is(cmd.Err(), ErrWrongArgCount) {
+		t.Fatal("should be ErrWrongArgCount, err=", cmd.Err())
+	}
+	_, err = cache.Expire("test1", 0).Result()
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("should be ErrInvalidArgument, err=", err)
+	}
+	err = cache.Close()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = cache.Get("test1").Result()
+	if !errors.Is(err, ErrClosed) {
+		t.Fatal("should be ErrClosed, err=", err)
+	}
+	if !errors.Is(cache.Close(), ErrClosed) {
+		t.Fatal("close twice should be ErrClosed")
+	}
+}
+
+func TestNilAndEmpty(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte(""))
+	res, err := cache.Get("test1").Result()
+	if res == nil || len(res) != 0 || err != nil {
+		t.Fatal("empty string should not be Nil")
+	}
+	cache.Set("test2", nil)
+	res, err = cache.Get("test2").Result()
+	if res == nil || err != nil {
+		t.Fatal("nil value should be stored as empty")
+	}
+	cache.HSet("key1", "field1", []byte(""))
+	res, err = cache.HGet("key1", "field1").Result()
+	if res == nil || err != nil {
+		t.Fatal("empty field should not be Nil")
+	}
+	_, err = cache.HGet("key1", "field2").Result()
+	if err != Nil {
+		t.Fatal("hget not exist field should return Nil")
+	}
+}
+
+func TestFlushDB(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	cache.HSet("key1", "field1", []byte("1"))
+	size, err := cache.DBSize().Result()
+	if size != 2 || err != nil {
+		t.Fatal("dbsize error")
+	}
+	cache.FlushDB()
+	size, err = cache.DBSize().Result()
+	if size != 0 || err != nil || cache.dbs[0].used != 0 {
+		t.Fatal("flushdb error")
+	}
+	_, err = cache.Get("test1").Result()
+	if err != Nil {
+		t.Fatal("get after flushdb should return Nil")
+	}
+}
+
+func TestMCStore(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	noExpire := time.Time{}
+	res, err := cache.MCStore(StoreReplace, &Item{Key: "test1", Value: []byte("1")}, noExpire).Result()
+	if res != NotStored || err != nil {
+		t.Fatal("replace not exist key should not be stored")
+	}
+	res, err = cache.MCStore(StoreAdd, &Item{Key: "test1", Value: []byte("1"), Flags: 7}, noExpire).Result()
+	if res != Stored || err != nil {
+		t.Fatal("add result error")
+	}
+	res, _ = cache.MCStore(StoreAdd, &Item{Key: "test1", Value: []byte("2")}, noExpire).Result()
+	if res != NotStored {
+		t.Fatal("add exist key should not be stored")
+	}
+	cache.MCStore(StoreAppend, &Item{Key: "test1", Value: []byte("0")}, noExpire)
+	items, err := cache.MCGet("test1", "notexist").Result()
+	if err != nil || len(items) != 1 || string(items[0].Value) != "10" || items[0].Flags != 7 {
+		t.Fatal("mcget result error")
+	}
+	cas := items[0].Cas
+	res, _ = cache.MCStore(StoreCas, &Item{Key: "test1", Value: []byte("3"), Cas: cas + 1}, noExpire).Result()
+	if res != Exists {
+		t.Fatal("cas mismatch should return Exists")
+	}
+	res, _ = cache.MCStore(StoreCas, &Item{Key: "test1", Value: []byte("3"), Cas: cas}, noExpire).Result()
+	if res != Stored {
+		t.Fatal("cas result error")
+	}
+	// redis set changes the cas
+	items, _ = cache.MCGet("test1").Result()
+	cas = items[0].Cas
+	cache.Set("test1", []byte("5"))
+	items, _ = cache.MCGet("test1").Result()
+	if items[0].Cas == cas || items[0].Flags != 0 {
+		t.Fatal("set should reset flags and cas")
+	}
+	val, err := cache.MCIncr("test1", 10).Result()
+	if string(val) != "15" || err != nil {
+		t.Fatal("incr result error")
+	}
+	val, _ = cache.MCDecr("test1", 100).Result()
+	if string(val) != "0" {
+		t.Fatal("decr should stop at 0")
+	}
+	_, err = cache.MCIncr("notexist", 1).Result()
+	if err != Nil {
+		t.Fatal("incr not exist key should return Nil")
+	}
+	touched, _ := cache.MCTouch("test1", time.Now().Add(-time.Second)).Result()
+	if touched != 1 {
+		t.Fatal("touch result error")
+	}
+	_, err = cache.Get("test1").Result()
+	if err != Nil {
+		t.Fatal("touch with past time should delete key")
+	}
+}
+
+func TestDo(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ctx := context.Background()
+	ok, err := cache.Do(ctx, "SET", "test1", "1").Bool()
+	if err != nil || !ok {
+		t.Fatal("do set error")
+	}
+	text, err := cache.Do(ctx, "get", "test1").Text()
+	if err != nil || text != "1" {
+		t.Fatal("do get error, text=", text)
+	}
+	n, err := cache.Do(ctx, "get", []byte("test1")).Int()
+	if err != nil || n != 1 {
+		t.Fatal("do get int error, n=", n)
+	}
+	n, err = cache.Do(ctx, "expire", "test1", "100").Int()
+	if err != nil || n != 1 {
+		t.Fatal("do expire error")
+	}
+	n, err = cache.Do(ctx, "sadd", "test2", "m1", "m2", 3).Int()
+	if err != nil || n != 3 {
+		t.Fatal("do sadd error, n=", n)
+	}
+	n, err = cache.Do(ctx, "del", "test1", "test2", "test3").Int()
+	if err != nil || n != 2 {
+		t.Fatal("do del error, n=", n)
+	}
+	_, err = cache.Do(ctx, "get", "test1").Text()
+	if err != Nil {
+		t.Fatal("do get not exist key should return Nil")
+	}
+	_, err = cache.Do(ctx, "get", "test1", "test2").Result()
+	if !errors.Is(err, ErrWrongArgCount) {
+		t.Fatal("should be ErrWrongArgCount, err=", err)
+	}
+	_, err = cache.Do(ctx, "expire", "test1", "abc").Result()
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("should be ErrInvalidArgument, err=", err)
+	}
+	items, err := cache.Do(ctx, "mcget", []string{"test1"}).Slice()
+	if err != nil || len(items) != 0 {
+		t.Fatal("do mcget error")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = cache.Do(canceled, "get", "test1").Result()
+	if err != context.Canceled {
+		t.Fatal("should be context.Canceled, err=", err)
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = cache.RegisterCommand("upsert-session", CommandSpec{
+		Arity:    4,
+		Flags:    FlagWrite | FlagDenyOOM,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		KeyType:  HASH,
+		Handler: func(tx *Tx, result IResult) {
+			key := result.Args()[0]
+			added, err := tx.Do("hset", key, "data", result.Args()[1]).Int()
+			if err != nil {
+				result.SetError(err)
+				return
+			}
+			err = tx.Do("expire", key, result.Args()[2]).Err()
+			if err != nil {
+				result.SetError(err)
+				return
+			}
+			result.SetVal(added)
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = cache.RegisterCommand("GET", CommandSpec{Handler: func(tx *Tx, result IResult) {}})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("register exist command should have error")
+	}
+	err = cache.RegisterCommand("nohandler", CommandSpec{})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("register without handler should have error")
+	}
+
+	ctx := context.Background()
+	args := []interface{}{"UPSERT-SESSION", "session1", "v1", 100}
+	n, err := cache.Do(ctx, args...).Int()
+	if err != nil || n != 1 {
+		t.Fatal("upsert-session error, err=", err)
+	}
+	if args[0] != "UPSERT-SESSION" {
+		t.Fatal("args of the caller should not be modified")
+	}
+	val, err := cache.HGet("session1", "data").Result()
+	if err != nil || string(val) != "v1" {
+		t.Fatal("hget error")
+	}
+	_, err = cache.Do(ctx, "upsert-session", "session1", "v1").Result()
+	if !errors.Is(err, ErrWrongArgCount) {
+		t.Fatal("should be ErrWrongArgCount, err=", err)
+	}
+	cache.Set("test1", []byte("1"))
+	_, err = cache.Do(ctx, "upsert-session", "test1", "v1", 100).Result()
+	if err != ErrWrongType {
+		t.Fatal("should be ErrWrongType, err=", err)
+	}
+	_, err = cache.Do(ctx, "notexist").Result()
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+
+	// the command is registered to the cache and its DB handles only
+	db1, _ := cache.DB(1)
+	if _, ok := db1.LookupCommand("upsert-session"); !ok {
+		t.Fatal("db handle should see the command")
+	}
+	other, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !errors.Is(other.Do(ctx, "upsert-session", "session1", "v1", 100).Err(), ErrUnknownCommand) {
+		t.Fatal("another cache should not see the command")
+	}
+	if !cache.UnregisterCommand("upsert-session") || cache.UnregisterCommand("get") {
+		t.Fatal("unregister command error")
+	}
+	if !errors.Is(cache.Do(ctx, "upsert-session", "session1", "v1", 100).Err(), ErrUnknownCommand) {
+		t.Fatal("unregistered command should be unknown")
+	}
+}
+
+func TestKeyPositions(t *testing.T) {
+	spec := CommandSpec{FirstKey: 1, LastKey: -1, KeyStep: 2}
+	pos := keyPositions(spec, 6)
+	if len(pos) != 3 || pos[0] != 1 || pos[1] != 3 || pos[2] != 5 {
+		t.Fatal("key positions error, pos=", pos)
+	}
+	if len(keyPositions(CommandSpec{}, 3)) != 0 {
+		t.Fatal("no keys error")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	infos := cache.Commands()
+	for i := 1; i < len(infos); i++ {
+		if infos[i-1].Name >= infos[i].Name {
+			t.Fatal("commands should be sorted by name")
+		}
+	}
+	info, ok := cache.LookupCommand("HSET")
+	if !ok || info.Arity != 4 || info.FirstKey != 1 || info.Group != "hash" {
+		t.Fatal("lookup hset error")
+	}
+	names := info.Flags.Names()
+	if len(names) != 3 || names[0] != "write" || names[1] != "denyoom" || names[2] != "fast" {
+		t.Fatal("hset flags error, names=", names)
+	}
+	if _, ok := cache.LookupCommand("mcget"); ok {
+		t.Fatal("internal command should not be found")
+	}
+}
+
+func TestUnknownAndPanic(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cmd := NewIntResult("notexist", "test1")
+	cache.doWithTransaction(cmd)
+	if !errors.Is(cmd.Err(), ErrUnknownCommand) {
+		t.Fatal("should be ErrUnknownCommand, err=", cmd.Err())
+	}
+	_, err = cache.Do(context.Background(), "notexist").Result()
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Fatal("should be ErrUnknownCommand, err=", err)
+	}
+	err = cache.RegisterCommand("panic", CommandSpec{Handler: func(tx *Tx, result IResult) {
+		tx.Do("set", "test1", "1")
+		panic("bad command")
+	}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = cache.Do(context.Background(), "panic").Result()
+	var panicErr *PanicError
+	if !errors.Is(err, ErrCommandPanic) || !errors.As(err, &panicErr) || panicErr.Value != "bad command" {
+		t.Fatal("should be ErrCommandPanic, err=", err)
+	}
+	// lock is released after the panic
+	val, err := cache.Get("test1").Result()
+	if err != nil || string(val) != "1" {
+		t.Fatal("get after panic error")
+	}
+}
+
+// panicArgsResult panics when the keys are extracted for the acl check,
+// outside of the command function
+type panicArgsResult struct {
+	*StringResult
+}
+
+func (r *panicArgsResult) Args() []interface{} {
+	panic("bad args")
+}
+
+func TestPanicOutsideCommand(t *testing.T) {
+	panicHook := HookFuncs{
+		Before: func(ctx context.Context, r IResult) (context.Context, error) {
+			if r.Name() == "dbsize" {
+				panic("bad hook")
+			}
+			return ctx, nil
+		},
+	}
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, Hooks: []Hook{panicHook}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = cache.DBSize().Result()
+	if !errors.Is(err, ErrCommandPanic) {
+		t.Fatal("hook panic should be ErrCommandPanic, err=", err)
+	}
+	r := &panicArgsResult{NewStringResult("get", "test1")}
+	ctx := WithClientInfo(context.Background(), ClientInfo{User: DefaultUser})
+	cache.doWithContext(ctx, r)
+	if !errors.Is(r.Err(), ErrCommandPanic) {
+		t.Fatal("key extraction panic should be ErrCommandPanic, err=", r.Err())
+	}
+	// lock is released after the panics
+	_, err = cache.Set("test1", []byte("1")).Result()
+	if err != nil {
+		t.Fatal("set after panic error")
+	}
+	if cache.Stats().Errors[ErrorTypePanic] != 2 {
+		t.Fatal("panics should be counted")
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "", true},
+		{"news.*", "news.tech", true},
+		{"news.*", "new.tech", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"a/*", "a/b/c", true},
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbx", false},
+		{"a*b?c", "abbbxc", true},
+		{"*[0-9]", "key9", true},
+		// exponential with naive backtracking
+		{"*a*a*a*a*a*a*a*a*a*a*a*a*a*a*b", strings.Repeat("a", 100), false},
+	}
+	for _, c := range cases {
+		if globMatch(c.pattern, c.s) != c.match {
+			t.Fatal("glob match error, pattern=", c.pattern, "s=", c.s)
+		}
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sub := cache.Subscribe("news.tech")
+	psub := cache.PSubscribe("news.*")
+	n, err := cache.Publish("news.tech", []byte("hello")).Result()
+	if err != nil || n != 2 {
+		t.Fatal("publish result error, n=", n)
+	}
+	msg := <-sub.Channel()
+	if msg.Channel != "news.tech" || msg.Pattern != "" || string(msg.Payload) != "hello" {
+		t.Fatal("message error")
+	}
+	msg = <-psub.Channel()
+	if msg.Pattern != "news.*" || string(msg.Payload) != "hello" {
+		t.Fatal("pattern message error")
+	}
+	channels, _ := cache.PubSubChannels("").Result()
+	if len(channels) != 1 || channels[0] != "news.tech" {
+		t.Fatal("pubsub channels error")
+	}
+	numSub, _ := cache.PubSubNumSub("news.tech", "news.sport").Result()
+	if numSub["news.tech"] != 1 || numSub["news.sport"] != 0 {
+		t.Fatal("pubsub numsub error")
+	}
+	if numPat, _ := cache.PubSubNumPat().Result(); numPat != 1 {
+		t.Fatal("pubsub numpat error")
+	}
+	sub.Unsubscribe()
+	if n, _ := cache.Publish("news.tech", []byte("hello")).Result(); n != 1 {
+		t.Fatal("publish after unsubscribe error, n=", n)
+	}
+	sub.Close()
+	if _, ok := <-sub.Channel(); ok {
+		t.Fatal("channel should be closed")
+	}
+	cache.Close()
+	if _, ok := <-psub.Channel(); !ok {
+		t.Fatal("message before close should be received")
+	}
+	if _, ok := <-psub.Channel(); ok || psub.Err() != ErrClosed {
+		t.Fatal("subscriber should be closed by Close")
+	}
+	if err := cache.Publish("news.tech", nil).Err(); err != ErrClosed {
+		t.Fatal("publish after close should be ErrClosed")
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	_, err := NewMemCache(&CacheConf{MaxSize: 10, PubSubSlowPolicy: "block"})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("unknown policy should have error")
+	}
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, PubSubBufferSize: 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sub := cache.Subscribe("ch")
+	cache.Publish("ch", []byte("1"))
+	cache.Publish("ch", []byte("2"))
+	if sub.Dropped() != 1 || len(sub.Channel()) != 1 {
+		t.Fatal("message should be dropped")
+	}
+
+	cache, err = NewMemCache(&CacheConf{MaxSize: 10, PubSubBufferSize: 1, PubSubSlowPolicy: DisconnectSubscriber})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sub = cache.Subscribe("ch")
+	cache.Publish("ch", []byte("1"))
+	cache.Publish("ch", []byte("2"))
+	if sub.Err() != ErrSlowSubscriber || sub.Subscriptions() != 0 {
+		t.Fatal("slow subscriber should be disconnected")
+	}
+	if n, _ := cache.Publish("ch", []byte("3")).Result(); n != 0 {
+		t.Fatal("disconnected subscriber should not receive")
+	}
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	_, err := NewMemCache(&CacheConf{MaxSize: 10, NotifyKeyspaceEvents: "KEQ"})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("invalid class should have error")
+	}
+	var events []KeyspaceEvent
+	var mu sync.Mutex
+	cache, err := NewMemCache(&CacheConf{
+		MaxSize:              2,
+		MaxMemoryPolicy:      AllKeysLRU,
+		NotifyKeyspaceEvents: "KEg$hxe",
+		OnKeyspaceEvent: func(event KeyspaceEvent) {
+			// called by the ttl goroutine too
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyspace := cache.Subscribe("__keyspace@0__:test1")
+	keyevent := cache.PSubscribe("__keyevent@0__:*")
+	cache.Set("test1", []byte("1"))
+	cache.HSet("test2", "field1", []byte("1"))
+	// sadd is not in the classes, test1 is evicted
+	cache.SAdd("test3", "member1")
+	cache.Set("test1", []byte("1"))
+	cache.Expire("test1", 100)
+	cache.Del("test1")
+	want := []KeyspaceEvent{
+		{0, "set", "test1"},
+		{0, "hset", "test2"},
+		{0, "evicted", "test1"},
+		{0, "evicted", "test2"},
+		{0, "set", "test1"},
+		{0, "expire", "test1"},
+		{0, "del", "test1"},
+	}
+	if len(events) != len(want) {
+		t.Fatal("events error, events=", events)
+	}
+	for i, ev := range want {
+		if events[i] != ev {
+			t.Fatal("events error, events=", events)
+		}
+	}
+	msg := <-keyspace.Channel()
+	if string(msg.Payload) != "set" {
+		t.Fatal("keyspace message error")
+	}
+	msg = <-keyevent.Channel()
+	if msg.Channel != "__keyevent@0__:set" || string(msg.Payload) != "test1" {
+		t.Fatal("keyevent message error")
+	}
+
+	mu.Lock()
+	events = nil
+	mu.Unlock()
+	cache.Set("test4", []byte("1"))
+	cache.Expire("test4", 1)
+	time.Sleep(1100 * time.Millisecond)
+	// expired by the ttl goroutine or by get
+	cache.Get("test4")
+	mu.Lock()
+	last := events[len(events)-1]
+	mu.Unlock()
+	if last.Event != "expired" || last.Key != "test4" {
+		t.Fatal("expired event error, events=", events)
+	}
+}
+
+func TestStatsAndInfo(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	cache.HSet("test2", "field", []byte("2"))
+	cache.Expire("test1", 100)
+	cache.Get("test1")
+	cache.Get("notexist")
+	if !errors.Is(cache.Set("test3", []byte("3")).Err(), ErrLimitExceeded) {
+		t.Fatal("set should be rejected by limit")
+	}
+	stats := cache.Stats()
+	if stats.CommandsProcessed != 6 || stats.KeyspaceHits != 1 || stats.KeyspaceMisses != 1 || stats.RejectedByLimit != 1 {
+		t.Fatal("commands stats error")
+	}
+	if cs := stats.Commands["get"]; cs.Calls != 2 || cs.Hits != 1 || cs.Misses != 1 {
+		t.Fatal("get stats error")
+	}
+	if cs := stats.Commands["set"]; cs.Calls != 2 || cs.Errors != 1 || cs.RejectedByLimit != 1 {
+		t.Fatal("set stats error")
+	}
+	if len(stats.Keyspace) != 1 {
+		t.Fatal("keyspace stats error")
+	}
+	ks := stats.Keyspace[0]
+	if ks.Keys != 2 || ks.Expires != 1 || ks.Strings != 1 || ks.Hashes != 1 || ks.AvgTTL <= 90*time.Second {
+		t.Fatal("keyspace stats of db0 error")
+	}
+	if stats.UsedMemory <= 0 {
+		t.Fatal("used memory stats error")
+	}
+	info, _ := cache.Info().Result()
+	if !strings.Contains(info, "# Keyspace\r\ndb0:keys=2,expires=1,") || strings.Contains(info, "cmdstat_") {
+		t.Fatal("default info error")
+	}
+	if !strings.Contains(info, "total_commands_processed:6\r\n") || !strings.Contains(info, "persistence_enabled:0\r\n") {
+		t.Fatal("info stats error")
+	}
+	info, _ = cache.Info("commandstats").Result()
+	if !strings.HasPrefix(info, "# Commandstats\r\ncmdstat_expire:calls=1,") || strings.Contains(info, "# Keyspace") {
+		t.Fatal("commandstats info error")
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	cache.HGet("test1", "field")
+	cache.Do(context.Background(), "notexist")
+	cache.Do(context.Background(), "get")
+	stats := cache.Stats()
+	set := stats.Commands["set"]
+	if set.Exec.Count != 1 || set.LockWait.Count != 1 || set.Exec.Cumulative()[NumLatencyBuckets-1] != 1 {
+		t.Fatal("set latency error")
+	}
+	if stats.Errors[ErrorTypeWrongType] != 1 || stats.Errors[ErrorTypeUnknownCommand] != 1 || stats.Errors[ErrorTypeWrongArgCount] != 1 {
+		t.Fatal("errors by type error")
+	}
+
+	var h Histogram
+	h.observe(time.Microsecond)
+	h.observe(time.Millisecond)
+	h.observe(2 * time.Second)
+	cumulative := h.Cumulative()
+	if h.Count != 3 || h.Counts[0] != 1 || h.Counts[6] != 1 || h.Counts[NumLatencyBuckets] != 1 || cumulative[6] != 2 || cumulative[NumLatencyBuckets-1] != 2 {
+		t.Fatal("histogram error")
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, SlowLogSlowerThan: 1000, SlowLogMaxLen: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = cache.RegisterCommand("sleep", CommandSpec{Arity: 2, Handler: func(tx *Tx, result IResult) {
+		time.Sleep(2 * time.Millisecond)
+		result.SetVal("OK")
+	}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Set("test1", []byte("1"))
+	ctx := WithClientInfo(context.Background(), ClientInfo{ID: 7, Name: "worker"})
+	for i := 0; i < 3; i++ {
+		cache.Do(ctx, "sleep", strconv.Itoa(i))
+	}
+	if n, _ := cache.SlowLogLen().Result(); n != 2 {
+		t.Fatal("slow log should only keep 2 entries")
+	}
+	entries, _ := cache.SlowLogGet(-1).Result()
+	if len(entries) != 2 || entries[0].ID != 2 || entries[1].ID != 1 {
+		t.Fatal("slow log should return the newest first")
+	}
+	if entries[0].Args[0] != "sleep" || entries[0].Args[1] != "2" || entries[0].Client.Name != "worker" || entries[0].Duration < 2*time.Millisecond {
+		t.Fatal("slow log entry error")
+	}
+	if entries, _ = cache.SlowLogGet(1).Result(); len(entries) != 1 || entries[0].ID != 2 {
+		t.Fatal("slow log get 1 error")
+	}
+	cache.SlowLogReset()
+	if n, _ := cache.SlowLogLen().Result(); n != 0 {
+		t.Fatal("slow log reset error")
+	}
+
+	args := make([]interface{}, 40)
+	args[0] = "sadd"
+	for i := 1; i < len(args); i++ {
+		args[i] = strings.Repeat("a", 130)
+	}
+	truncated := slowLogArgs(NewIntResult(args...))
+	if len(truncated) != 32 || truncated[31] != "... (9 more arguments)" || truncated[1] != strings.Repeat("a", 128)+"... (2 more bytes)" {
+		t.Fatal("slow log args should be truncated")
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, MonitorBufferSize: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := cache.Monitor()
+	db1, _ := cache.DB(1)
+	ctx := WithClientInfo(context.Background(), ClientInfo{ID: 1, Addr: "127.0.0.1:6000"})
+	db1.Do(ctx, "set", "test1", []byte("a \"b\"\n"))
+	cache.Get("test1")
+	cache.Get("test2")
+	ev := <-m.Channel()
+	if ev.DB != 1 || ev.Client.ID != 1 || len(ev.Args) != 3 || ev.Args[0] != "set" || ev.Args[2] != "a \"b\"\n" {
+		t.Fatal("monitor event error")
+	}
+	if !strings.HasSuffix(ev.String(), ` [1 127.0.0.1:6000] "set" "test1" "a \"b\"\n"`) {
+		t.Fatal("monitor event string error", ev.String())
+	}
+	if ev = <-m.Channel(); ev.DB != 0 || ev.Args[0] != "get" {
+		t.Fatal("monitor event of db 0 error")
+	}
+	if m.Dropped() != 1 {
+		t.Fatal("event should be dropped when the buffer is full")
+	}
+	m.Close()
+	if _, ok := <-m.Channel(); ok {
+		t.Fatal("channel should be closed")
+	}
+	cache.Get("test1")
+
+	m = cache.Monitor()
+	cache.Close()
+	if _, ok := <-m.Channel(); ok {
+		t.Fatal("channel should be closed by Close of MemCache")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var calls []string
+	var cache *MemCache
+	errRejected := errors.New("rejected")
+	audit := HookFuncs{
+		Before: func(ctx context.Context, r IResult) (context.Context, error) {
+			calls = append(calls, "before audit "+r.Name())
+			// the client is seen by the monitors
+			return WithClientInfo(ctx, ClientInfo{Name: "audit"}), nil
+		},
+		After: func(ctx context.Context, r IResult, d time.Duration) {
+			calls = append(calls, "after audit "+r.Name())
+			if r.Err() != nil {
+				calls = append(calls, "error "+r.Err().Error())
+			}
+			if d <= 0 {
+				t.Error("duration should be positive")
+			}
+			if r.Name() == "set" {
+				// hooks run without the data lock
+				cache.DBSize()
+			}
+		},
+	}
+	validate := HookFuncs{
+		Before: func(ctx context.Context, r IResult) (context.Context, error) {
+			calls = append(calls, "before validate "+r.Name())
+			if r.Name() == "set" && r.stringArg(1) == "" {
+				return ctx, errRejected
+			}
+			return ctx, nil
+		},
+		After: func(ctx context.Context, r IResult, d time.Duration) {
+			calls = append(calls, "after validate "+r.Name())
+		},
+	}
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, Hooks: []Hook{audit, validate}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := cache.Monitor()
+	cache.Set("test1", []byte("1"))
+	if ev := <-m.Channel(); ev.Client.Name != "audit" {
+		t.Fatal("context of the hook should be passed to the command")
+	}
+	m.Close()
+	calls = nil
+	if !errors.Is(cache.Set("", []byte("1")).Err(), errRejected) {
+		t.Fatal("set should be rejected by the hook")
+	}
+	expected := []string{"before audit set", "before validate set", "after audit set", "error rejected", "before audit dbsize", "before validate dbsize", "after validate dbsize", "after audit dbsize"}
+	if len(calls) != len(expected) {
+		t.Fatal("hook calls error", calls)
+	}
+	for i := range calls {
+		if calls[i] != expected[i] {
+			t.Fatal("hook calls error", calls)
+		}
+	}
+	if n, _ := cache.DBSize().Result(); n != 1 {
+		t.Fatal("rejected command should not run")
+	}
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &InMemoryTracer{}
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10, Tracer: tracer})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ctx, parent := tracer.Start(context.Background(), "request")
+	cache.SetContext(ctx, "test1", []byte("hello"))
+	cache.GetContext(ctx, "test1")
+	cache.HGetContext(ctx, "test1", "field")
+	cache.DelContext(ctx, "test2", "test1")
+	spans := tracer.Spans()
+	if len(spans) != 4 {
+		t.Fatal("every command should have a span")
+	}
+	get := spans[1]
+	if get.Name != "get" || get.Parent != parent || get.Attributes[AttrDBOperation] != "get" || get.Attributes[AttrDBKey] != "test1" {
+		t.Fatal("get span error")
+	}
+	if get.Attributes[AttrDBResultSize] != 5 || get.Err != nil || get.EndTime.Before(get.StartTime) {
+		t.Fatal("get span result error")
+	}
+	if !errors.Is(spans[2].Err, ErrWrongType) {
+		t.Fatal("hget span should have the error")
+	}
+	if spans[3].Attributes[AttrDBKey] != "test2" {
+		t.Fatal("del span should have the first key")
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if cache.GetContext(canceled, "test1").Err() != context.Canceled {
+		t.Fatal("canceled context should have error")
+	}
+
+	tracer.Reset()
+	cache, err = NewMemCache(&CacheConf{MaxSize: 10, Tracer: tracer, TraceHashKeys: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.Get("test1")
+	spans = tracer.Spans()
+	if len(spans) != 1 || spans[0].Parent != nil || spans[0].Attributes[AttrDBKey] == "test1" || len(spans[0].Attributes[AttrDBKey].(string)) != 32 {
+		t.Fatal("key should be hashed")
+	}
+	if _, ok := spans[0].Attributes[AttrDBResultSize]; ok {
+		t.Fatal("nil result should have no size")
+	}
+}
+
+func TestACL(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cache.ACLAuth(DefaultUser, "any").Err() != nil {
+		t.Fatal("default user should accept any password")
+	}
+	err = cache.ACLSetUser("alice", "on", ">secret", "~app:*", "&news.*", "+@read", "+@hash", "-hdel").Err()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !errors.Is(cache.ACLSetUser("alice", "off", "+@notexist").Err(), ErrInvalidArgument) {
+		t.Fatal("unknown category should have error")
+	}
+	info, err := cache.ACLGetUser("alice").Result()
+	if err != nil || !info.Enabled || info.Commands != "-@all +@read +@hash -hdel" || len(info.Passwords) != 1 || info.Keys[0] != "app:*" {
+		t.Fatal("invalid rules should change nothing", info)
+	}
+	if cache.ACLAuth("alice", "wrong").Err() != ErrWrongPass || cache.ACLAuth("alice", "secret").Err() != nil {
+		t.Fatal("auth result error")
+	}
+
+	alice := WithClientInfo(context.Background(), ClientInfo{User: "alice"})
+	cache.Set("app:1", []byte("1"))
+	if v, err := cache.GetContext(alice, "app:1").Result(); err != nil || string(v) != "1" {
+		t.Fatal("alice should read app keys")
+	}
+	if !errors.Is(cache.GetContext(alice, "other").Err(), ErrNoPermission) {
+		t.Fatal("alice should not read other keys")
+	}
+	if err := cache.SetContext(alice, "app:1", []byte("2")).Err(); !errors.Is(err, ErrNoPermission) || err.Error() != "NOPERM User alice has no permissions to run the 'set' command" {
+		t.Fatal("alice should not run set")
+	}
+	if cache.HSetContext(alice, "app:2", "f", []byte("1")).Err() != nil {
+		t.Fatal("alice should run hset")
+	}
+	if !errors.Is(cache.HDelContext(alice, "app:2", "f").Err(), ErrNoPermission) {
+		t.Fatal("alice should not run hdel")
+	}
+	if !errors.Is(cache.DelContext(alice, "app:1", "other").Err(), ErrNoPermission) {
+		t.Fatal("every key should be checked")
+	}
+	if cache.Stats().Errors[ErrorTypeNoPermission] != 4 {
+		t.Fatal("noperm errors should be counted")
+	}
+	flushdb, _ := cache.LookupCommand("flushdb")
+	if cache.ACLCheck("alice", flushdb) == nil || cache.ACLCheck(DefaultUser, flushdb) != nil {
+		t.Fatal("acl check error")
+	}
+	cache.ACLSetUser("alice", "+@all", "-@dangerous")
+	if cache.ACLCheck("alice", flushdb) == nil || cache.SetContext(alice, "app:1", []byte("2")).Err() != nil {
+		t.Fatal("+@all should override the rules before")
+	}
+	if cache.ACLCheckChannel("alice", "news.sport", false) != nil || cache.ACLCheckChannel("alice", "other", false) == nil {
+		t.Fatal("channel check error")
+	}
+	if cache.ACLCheckChannel("alice", "news.*", true) != nil || cache.ACLCheckChannel("alice", "news.s*", true) == nil {
+		t.Fatal("pattern check error")
+	}
+
+	list, _ := cache.ACLList().Result()
+	if len(list) != 2 || !strings.HasPrefix(list[0], "user alice on #") || list[1] != "user default on nopass ~* &* +@all" {
+		t.Fatal("acl list error", list)
+	}
+	if !errors.Is(cache.ACLDelUser(DefaultUser).Err(), ErrInvalidArgument) {
+		t.Fatal("default user should not be deleted")
+	}
+	if n, _ := cache.ACLDelUser("alice", "bob").Result(); n != 1 {
+		t.Fatal("acl deluser error")
+	}
+	if !errors.Is(cache.GetContext(alice, "app:1").Err(), ErrNoPermission) || cache.ACLAuth("alice", "secret").Err() != ErrWrongPass {
+		t.Fatal("deleted user should have no permission")
+	}
+	if cache.ACLGetUser("alice").Err() != Nil {
+		t.Fatal("deleted user should not exist")
+	}
+}
+
 func TestGetBench(t *testing.T) {
 	cache, err := NewMemCache(&CacheConf{MaxSize: 175000})
 	if err != nil {
@@ -308,11 +1514,11 @@ func TestGetBench(t *testing.T) {
 			defer wg.Done()
 			_, err = cache.Set(strconv.Itoa(count), []byte("1")).Result()
 			if err != nil {
-				t.Fatal(err.Error())
+				t.Error(err.Error())
 			}
 			res, err := cache.Get(strconv.Itoa(count)).Result()
 			if string(res) != "1" || err != nil {
-				t.Fatal("res=", res)
+				t.Error("res=", res)
 			}
 		}(i)
 	}
@@ -335,15 +1541,68 @@ func TestExpireBench(t *testing.T) {
 			defer wg.Done()
 			_, err = cache.Set(strconv.Itoa(count), []byte("1")).Result()
 			if err != nil {
-				t.Fatal(err.Error())
+				t.Error(err.Error())
 			}
 			cache.Expire(strconv.Itoa(count), 1)
 			time.Sleep(time.Second * 2)
 			res, err := cache.Get(strconv.Itoa(count)).Result()
-			if string(res) != "" || err != nil {
-				t.Fatal("res=", res)
+			if res != nil || err != Nil {
+				t.Error("res=", res)
 			}
 		}(i)
 	}
 	wg.Wait()
 }
+
+func TestSetEX(t *testing.T) {
+	cache, err := NewMemCache(&CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ctx := context.Background()
+	if err := cache.SetEXContext(ctx, "test1", []byte("1"), 100).Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := cache.dbs[0].ttl["test1"]; !ok {
+		t.Fatal("setex ttl error")
+	}
+	if n, err := cache.SAddEXContext(ctx, "set1", 100, "a", "b").Result(); err != nil || n != 2 {
+		t.Fatal("saddex error")
+	}
+	// a failed write sets no ttl
+	cache.Set("test2", []byte("2"))
+	if err := cache.HSetEXContext(ctx, "test2", "field1", []byte("1"), 100).Err(); !errors.Is(err, ErrWrongType) {
+		t.Fatal("hsetex on a string should return ErrWrongType")
+	}
+	if _, ok := cache.dbs[0].ttl["test2"]; ok {
+		t.Fatal("failed hsetex should not set the ttl")
+	}
+	if err := cache.SetEXContext(ctx, "test3", []byte("1"), 0).Err(); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatal("setex with 0 seconds should return ErrInvalidArgument")
+	}
+	if _, err := cache.Get("test3").Result(); err != Nil {
+		t.Fatal("setex with 0 seconds should not set the key")
+	}
+	// the write and the expire are checked by the acl, not exec
+	cache.ACLSetUser("app", "on", "nopass", "~app:*", "+@all")
+	ctx = WithClientInfo(context.Background(), ClientInfo{User: "app"})
+	if err := cache.SetEXContext(ctx, "secret", []byte("x"), 100).Err(); !errors.Is(err, ErrNoPermission) {
+		t.Fatal("setex of a key out of the key pattern should return ErrNoPermission")
+	}
+	if _, err := cache.Get("secret").Result(); err != Nil {
+		t.Fatal("setex denied by acl should not run")
+	}
+	if err := cache.SetEXContext(ctx, "app:1", []byte("x"), 100).Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.ACLSetUser("writer", "on", "nopass", "~*", "+set", "+expire")
+	ctx = WithClientInfo(context.Background(), ClientInfo{User: "writer"})
+	if err := cache.SetEXContext(ctx, "test4", []byte("x"), 100).Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+	cache.ACLSetUser("setter", "on", "nopass", "~*", "+set")
+	ctx = WithClientInfo(context.Background(), ClientInfo{User: "setter"})
+	if err := cache.SetEXContext(ctx, "test5", []byte("x"), 100).Err(); !errors.Is(err, ErrNoPermission) {
+		t.Fatal("setex without expire permission should return ErrNoPermission")
+	}
+}
@@ -0,0 +1,28 @@
+package cache
+
+import "context"
+
+// ClientInfo identifies the caller of a command in the slow log and the
+// monitors, it is supplied by the caller with WithClientInfo
+type ClientInfo struct {
+	ID   int64
+	Name string
+	Addr string
+	// User is the acl user the commands are checked with, empty means
+	// the caller is trusted and nothing is checked
+	User string
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo returns a context carrying client, commands run by Do with
+// the context are logged and monitored with client
+func WithClientInfo(ctx context.Context, client ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, client)
+}
+
+// ClientInfoFromContext returns the client of ctx, false if ctx has none
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	client, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return client, ok
+}
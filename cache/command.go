@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandFlag describes the behavior of a command, like the flags of redis COMMAND
+type CommandFlag uint32
+
+const (
+	// FlagWrite command may modify the data
+	FlagWrite CommandFlag = 1 << iota
+	// FlagReadOnly command never modifies the data
+	FlagReadOnly
+	// FlagDenyOOM command is rejected when the memory limit is reached and nothing can be evicted
+	FlagDenyOOM
+	// FlagFast command runs in O(1) or O(log(N))
+	FlagFast
+)
+
+// CommandFunc executes a registered command with the data lock held,
+// arguments are result.Args() and the return value is set by result.SetVal
+type CommandFunc func(tx *Tx, result IResult)
+
+// CommandSpec declares a command for RegisterCommand
+type CommandSpec struct {
+	// Arity like redis including the command name, negative means at least -Arity,
+	// 0 means not checked
+	Arity int
+	Flags CommandFlag
+	// positions of the key arguments like redis, the command name is position 0,
+	// negative LastKey counts from the end, FirstKey 0 means no keys
+	FirstKey int
+	LastKey  int
+	KeyStep  int
+	// KeyType is checked for every key before Handler, WRONGTYPE if a key holds
+	// another type, DEFAULT accepts any type
+	KeyType ValueType
+	Handler CommandFunc
+	// Group and Summary are reported by COMMAND DOCS
+	Group   string
+	Summary string
+	// Categories are acl categories besides the ones of Flags and Group, like dangerous
+	Categories []string
+}
+
+// command is an entry of the command table, fn is called by doWithTransaction
+type command struct {
+	name string
+	spec CommandSpec
+	fn   func(db *MemCacheDB, result IResult)
+	// argument types of the built-in commands for Do, nil means no conversion
+	args []argKind
+	// internal commands are callable by Do but not listed by Commands
+	internal bool
+}
+
+// builtinCommands is written by init only, the commands of RegisterCommand
+// are kept by every cache
+var builtinCommands = map[string]*command{}
+
+// register a built-in command, fn is a method expression of MemCacheDB
+func register(name string, spec CommandSpec, fn func(db *MemCacheDB, result IResult), args ...argKind) {
+	builtinCommands[name] = &command{name: name, spec: spec, fn: fn, args: args}
+}
+
+// registerInternal registers a built-in command not listed by Commands
+func registerInternal(name string, spec CommandSpec, fn func(db *MemCacheDB, result IResult)) {
+	builtinCommands[name] = &command{name: name, spec: spec, fn: fn, internal: true}
+}
+
+func init() {
+	// add a command init function when add a new data structure
+	commandString()
+	commandHashMap()
+	commandHashSet()
+	commandDB()
+	commandMemory()
+	commandObject()
+	commandMemcached()
+	commandExec()
+}
+
+func unknownCommand(name string) error {
+	return fmt.Errorf("%w '%s'", ErrUnknownCommand, name)
+}
+
+// lookupCommand returns the built-in or registered command of name, nil if there is none
+func (c *core) lookupCommand(name string) *command {
+	if cmd, ok := builtinCommands[name]; ok {
+		return cmd
+	}
+	c.commandsMu.RLock()
+	defer c.commandsMu.RUnlock()
+	return c.commands[name]
+}
+
+// RegisterCommand adds a command callable by Do to the cache and every DB
+// handle of it, other caches of the process don't see it. It runs with the
+// data lock held like the built-in commands. Keys of spec are expired if
+// needed and type checked before Handler, and with FlagDenyOOM the memory
+// limit is checked too. A built-in or registered command can't be replaced.
+func (s *MemCache) RegisterCommand(name string, spec CommandSpec) error {
+	name = strings.ToLower(name)
+	if name == "" {
+		return fmt.Errorf("%w: command name can't be empty", ErrInvalidArgument)
+	}
+	if spec.Handler == nil {
+		return fmt.Errorf("%w: command %s has no handler", ErrInvalidArgument, name)
+	}
+	if spec.FirstKey < 0 || (spec.FirstKey > 0 && spec.KeyStep <= 0) {
+		return fmt.Errorf("%w: command %s has invalid key positions", ErrInvalidArgument, name)
+	}
+	if spec.Flags&FlagWrite != 0 && spec.Flags&FlagReadOnly != 0 {
+		return fmt.Errorf("%w: command %s can't be both write and readonly", ErrInvalidArgument, name)
+	}
+	if _, ok := builtinCommands[name]; ok {
+		return fmt.Errorf("%w: command %s already exists", ErrInvalidArgument, name)
+	}
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+	if _, ok := s.commands[name]; ok {
+		return fmt.Errorf("%w: command %s already exists", ErrInvalidArgument, name)
+	}
+	s.commands[name] = &command{name: name, spec: spec, fn: customCommand(spec)}
+	return nil
+}
+
+// UnregisterCommand removes a command added by RegisterCommand, false if
+// there is none, built-in commands can't be removed
+func (s *MemCache) UnregisterCommand(name string) bool {
+	name = strings.ToLower(name)
+	s.commandsMu.Lock()
+	defer s.commandsMu.Unlock()
+	if _, ok := s.commands[name]; !ok {
+		return false
+	}
+	delete(s.commands, name)
+	return true
+}
+
+// customCommand checks the keys of spec before calling the handler
+func customCommand(spec CommandSpec) func(db *MemCacheDB, result IResult) {
+	return func(db *MemCacheDB, result IResult) {
+		for _, pos := range keyPositions(spec, len(result.Args())+1) {
+			key, err := toString(result.Args()[pos-1])
+			if err != nil {
+				result.SetError(fmt.Errorf("%w: %s key %v", ErrInvalidArgument, result.Name(), err))
+				return
+			}
+			err = db.doBeforeProcess(key, spec.KeyType)
+			if err != nil {
+				result.SetError(err)
+				return
+			}
+		}
+		if spec.Flags&FlagDenyOOM != 0 {
+			err := db.checkMemory()
+			if err != nil {
+				result.SetError(err)
+				return
+			}
+		}
+		spec.Handler(&Tx{db: db}, result)
+	}
+}
+
+// keyPositions returns the positions of keys in a command of argc arguments
+func keyPositions(spec CommandSpec, argc int) []int {
+	if spec.FirstKey <= 0 || spec.FirstKey >= argc {
+		return nil
+	}
+	last := spec.LastKey
+	if last < 0 {
+		last += argc
+	}
+	if last >= argc {
+		last = argc - 1
+	}
+	var positions []int
+	for pos := spec.FirstKey; pos <= last; pos += spec.KeyStep {
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+// checkArity reports the wrong number of arguments of args including the command name
+func (spec *CommandSpec) checkArity(name string, argc int) error {
+	if (spec.Arity > 0 && argc != spec.Arity) || (spec.Arity < 0 && argc < -spec.Arity) {
+		return fmt.Errorf("%w for '%s' command", ErrWrongArgCount, name)
+	}
+	return nil
+}
+
+var flagNames = []struct {
+	flag CommandFlag
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadOnly, "readonly"},
+	{FlagDenyOOM, "denyoom"},
+	{FlagFast, "fast"},
+}
+
+// Names returns the redis names of the flags, like write and readonly
+func (f CommandFlag) Names() []string {
+	names := []string{}
+	for _, fn := range flagNames {
+		if f&fn.flag != 0 {
+			names = append(names, fn.name)
+		}
+	}
+	return names
+}
+
+// CommandInfo describes a registered command for COMMAND INFO and COMMAND DOCS
+type CommandInfo struct {
+	Name     string
+	Arity    int
+	Flags    CommandFlag
+	FirstKey int
+	LastKey  int
+	KeyStep  int
+	Group    string
+	Summary  string
+	// Categories are the extra acl categories, see ACLCategories
+	Categories []string
+}
+
+func (c *command) info() CommandInfo {
+	return CommandInfo{
+		Name:     c.name,
+		Arity:    c.spec.Arity,
+		Flags:    c.spec.Flags,
+		FirstKey: c.spec.FirstKey,
+		LastKey:  c.spec.LastKey,
+		KeyStep:  c.spec.KeyStep,
+		Group:    c.spec.Group,
+		Summary:  c.spec.Summary,
+
+		Categories: c.spec.Categories,
+	}
+}
+
+// Commands returns the built-in commands and the registered ones of the
+// cache sorted by name
+func (s *MemCache) Commands() []CommandInfo {
+	s.commandsMu.RLock()
+	defer s.commandsMu.RUnlock()
+	infos := make([]CommandInfo, 0, len(builtinCommands)+len(s.commands))
+	for _, commands := range []map[string]*command{builtinCommands, s.commands} {
+		for _, c := range commands {
+			if !c.internal {
+				infos = append(infos, c.info())
+			}
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// LookupCommand returns the command of name, false if it is neither
+// built-in nor registered to the cache
+func (s *MemCache) LookupCommand(name string) (CommandInfo, bool) {
+	c := s.lookupCommand(strings.ToLower(name))
+	if c == nil || c.internal {
+		return CommandInfo{}, false
+	}
+	return c.info(), true
+}
+
+// Tx is the database a registered command runs in, the data lock is held
+// so the commands run by Tx are atomic with the registered command
+type Tx struct {
+	db *MemCacheDB
+}
+
+// Index returns the index of the database
+func (tx *Tx) Index() int {
+	return tx.db.id
+}
+
+// Do runs a command in the database like MemCache.Do, without locking
+func (tx *Tx) Do(args ...interface{}) *Cmd {
+	cmd, c := tx.db.core.prepareCmd(args)
+	if cmd.Err() != nil {
+		return cmd
+	}
+	c.fn(tx.db, cmd)
+	return cmd
+}
@@ -1,6 +1,46 @@
 package cache
 
+// DefaultDatabases is used when CacheConf.Databases is not set
+const DefaultDatabases = 16
+
 type CacheConf struct {
+	// MaxSize is the keys count limit of every database
 	MaxSize             int
 	TtlPeriodMillSecond int
+	// Databases is the number of databases, default DefaultDatabases
+	Databases int
+	// MaxMemoryBytes is the estimated memory limit of every database, 0 means no limit
+	MaxMemoryBytes int64
+	// MaxMemoryPolicy is used when MaxSize or MaxMemoryBytes is reached, default NoEviction
+	MaxMemoryPolicy EvictionPolicy
+	// MaxMemorySamples is the count of keys sampled for every eviction, default DefaultMaxMemorySamples
+	MaxMemorySamples int
+	// LfuLogFactor is lfu-log-factor, the higher the more hits are needed to increase the counter, default DefaultLfuLogFactor
+	LfuLogFactor int
+	// LfuDecayTime is lfu-decay-time, the counter is decremented by 1 every LfuDecayTime idle minutes,
+	// 0 is DefaultLfuDecayTime, LfuNeverDecay or any negative value never decays
+	LfuDecayTime int
+	// PubSubBufferSize is the channel size of every subscriber, default DefaultPubSubBufferSize
+	PubSubBufferSize int
+	// PubSubSlowPolicy is used when the channel of a subscriber is full, default DropMessage
+	PubSubSlowPolicy SlowSubscriberPolicy
+	// NotifyKeyspaceEvents is the class mask like notify-keyspace-events of redis,
+	// K and E publish to the keyspace and keyevent channels, empty means disabled
+	NotifyKeyspaceEvents string
+	// OnKeyspaceEvent is called with the events of the classes in NotifyKeyspaceEvents,
+	// after the data lock is released, so it can call the MemCache
+	OnKeyspaceEvent func(event KeyspaceEvent)
+	// SlowLogSlowerThan is slowlog-log-slower-than in microseconds, commands running
+	// at least this long are logged, default DefaultSlowLogSlowerThan, negative disables
+	SlowLogSlowerThan int
+	// SlowLogMaxLen is the count of entries kept by the slow log, default DefaultSlowLogMaxLen
+	SlowLogMaxLen int
+	// MonitorBufferSize is the channel size of every Monitor, default DefaultMonitorBufferSize
+	MonitorBufferSize int
+	// Hooks run around every command, BeforeProcess in this order and AfterProcess in the reverse order
+	Hooks []Hook
+	// Tracer starts a span for every command before the hooks, nil traces nothing
+	Tracer Tracer
+	// TraceHashKeys replaces the key attribute of the spans with its sha256 prefix
+	TraceHashKeys bool
 }
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// register cmd when add a operate
+func commandDB() {
+	register("move", CommandSpec{
+		Arity:    3,
+		Flags:    FlagWrite | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "generic",
+		Summary:  "Move a key to another database",
+	}, (*MemCacheDB).move, argString, argInt)
+	register("swapdb", CommandSpec{
+		Arity:   3,
+		Flags:   FlagWrite | FlagFast,
+		Group:   "server",
+		Summary: "Swap two databases",
+
+		Categories: []string{"dangerous"},
+	}, (*MemCacheDB).swapDB, argInt, argInt)
+	register("flushdb", CommandSpec{
+		Arity:   1,
+		Flags:   FlagWrite,
+		Group:   "server",
+		Summary: "Remove all keys from the current database",
+
+		Categories: []string{"dangerous"},
+	}, (*MemCacheDB).flushDB)
+	register("dbsize", CommandSpec{
+		Arity:   1,
+		Flags:   FlagReadOnly | FlagFast,
+		Group:   "server",
+		Summary: "Return the number of keys in the current database",
+	}, (*MemCacheDB).dbSize)
+}
+
+// return true
+func (db *MemCacheDB) flushDB(result IResult) {
+	if len(result.Args()) != 0 {
+		result.SetError(fmt.Errorf("%w: flushdb need 0 argument", ErrWrongArgCount))
+		return
+	}
+	db.keys = make(map[string]ValueType)
+	db.ttl = make(map[string]time.Time)
+	db.s = initStr()
+	db.hm = initHmap()
+	db.hs = initHset()
+	db.smeta = make(map[string]stringMeta)
+	db.mem = make(map[string]int64)
+	db.access = make(map[string]keyAccess)
+	db.count = 0
+	db.used = 0
+	result.SetVal(true)
+}
+
+// return keys count, keys expired but not deleted yet are counted
+func (db *MemCacheDB) dbSize(result IResult) {
+	if len(result.Args()) != 0 {
+		result.SetError(fmt.Errorf("%w: dbsize need 0 argument", ErrWrongArgCount))
+		return
+	}
+	result.SetVal(db.count)
+}
+
+// moved return 1, key not exist or already exist in target db return 0
+func (db *MemCacheDB) move(result IResult) {
+	if len(result.Args()) != 2 {
+		result.SetError(fmt.Errorf("%w: move need 2 argument", ErrWrongArgCount))
+		return
+	}
+	arg0, ok := result.Args()[0].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: move argument 1 should be string", ErrInvalidArgument))
+		return
+	}
+	arg1, ok := result.Args()[1].(int)
+	if !ok || arg1 < 0 || arg1 >= len(db.core.dbs) {
+		result.SetError(fmt.Errorf("%w: DB index is out of range", ErrInvalidArgument))
+		return
+	}
+	dst := db.core.dbs[arg1]
+	if dst == db {
+		result.SetError(fmt.Errorf("%w: source and destination objects are the same", ErrInvalidArgument))
+		return
+	}
+	err := db.doBeforeProcess(arg0, DEFAULT)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	err = dst.doBeforeProcess(arg0, DEFAULT)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	err = dst.checkMemory()
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if db.keys[arg0] == DEFAULT || dst.keys[arg0] != DEFAULT {
+		result.SetVal(0)
+		return
+	}
+	err = db.moveKey(arg0, dst)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	db.notify(notifyGeneric, "move_from", arg0)
+	dst.notify(notifyGeneric, "move_to", arg0)
+	result.SetVal(1)
+}
+
+// return true
+func (db *MemCacheDB) swapDB(result IResult) {
+	if len(result.Args()) != 2 {
+		result.SetError(fmt.Errorf("%w: swapdb need 2 argument", ErrWrongArgCount))
+		return
+	}
+	dbs := db.core.dbs
+	arg0, ok := result.Args()[0].(int)
+	if !ok || arg0 < 0 || arg0 >= len(dbs) {
+		result.SetError(fmt.Errorf("%w: invalid first DB index", ErrInvalidArgument))
+		return
+	}
+	arg1, ok := result.Args()[1].(int)
+	if !ok || arg1 < 0 || arg1 >= len(dbs) {
+		result.SetError(fmt.Errorf("%w: invalid second DB index", ErrInvalidArgument))
+		return
+	}
+	// handles keep their index, so they see the swapped data
+	dbs[arg0], dbs[arg1] = dbs[arg1], dbs[arg0]
+	dbs[arg0].id, dbs[arg1].id = arg0, arg1
+	result.SetVal(true)
+}
+
+// moveKey transfers key with its value and ttl to dst, key must not exist in dst
+func (db *MemCacheDB) moveKey(key string, dst *MemCacheDB) error {
+	valueType := db.keys[key]
+	_, err := dst.addKey(key, valueType)
+	if err != nil {
+		return err
+	}
+	switch valueType {
+	case STRING:
+		dst.s[key] = db.s[key]
+		if meta, ok := db.smeta[key]; ok {
+			dst.smeta[key] = meta
+		}
+	case HASH:
+		dst.hm[key] = db.hm[key]
+	case Set:
+		dst.hs[key] = db.hs[key]
+	}
+	if expireTime, ok := db.ttl[key]; ok {
+		dst.ttl[key] = expireTime
+	}
+	dst.incrMem(key, db.mem[key]-dst.mem[key])
+	dst.access[key] = db.access[key]
+	db.delKey(key, true)
+	return nil
+}
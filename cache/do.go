@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// argKind is the type of a command argument expected by the CommandFunc
+type argKind int
+
+const (
+	argString argKind = iota
+	argBytes
+	argInt
+	// the rest arguments collected into a []string
+	argStrings
+)
+
+// convertArgs converts args to the types of kinds, nil kinds keeps args
+// as they are, extra args are kept so the command reports the wrong count
+func convertArgs(name string, kinds []argKind, args []interface{}) ([]interface{}, error) {
+	if kinds == nil {
+		return args, nil
+	}
+	converted := make([]interface{}, 0, len(args))
+	rest := len(kinds)
+	for i, kind := range kinds {
+		if i >= len(args) {
+			break
+		}
+		var arg interface{}
+		var err error
+		switch kind {
+		case argString:
+			arg, err = toString(args[i])
+		case argBytes:
+			arg, err = toBytes(args[i])
+		case argInt:
+			arg, err = toInt(args[i])
+		case argStrings:
+			arg, err = toStrings(args[i:])
+			rest = len(args)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s argument %d %v", ErrInvalidArgument, name, i+1, err)
+		}
+		converted = append(converted, arg)
+	}
+	if len(args) > rest {
+		converted = append(converted, args[rest:]...)
+	}
+	return converted, nil
+}
+
+func toString(arg interface{}) (string, error) {
+	switch v := arg.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	default:
+		return "", fmt.Errorf("can't be %T", arg)
+	}
+}
+
+func toBytes(arg interface{}) ([]byte, error) {
+	if v, ok := arg.([]byte); ok {
+		return v, nil
+	}
+	s, err := toString(arg)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+var errNotInteger = errors.New("value is not an integer or out of range")
+
+func toInt(arg interface{}) (int, error) {
+	switch v := arg.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case string, []byte:
+		s, _ := toString(v)
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, errNotInteger
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("can't be %T", arg)
+	}
+}
+
+// toStrings accepts a single []string or every argument convertible to string
+func toStrings(args []interface{}) ([]string, error) {
+	if len(args) == 1 {
+		if v, ok := args[0].([]string); ok {
+			return v, nil
+		}
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, err := toString(arg)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+// prepareCmd looks up the command of args[0], checks the arity and converts
+// the arguments, the error is set to the returned Cmd
+func (c *core) prepareCmd(args []interface{}) (*Cmd, *command) {
+	cmd := NewCmd(args...)
+	if len(args) == 0 {
+		cmd.SetError(fmt.Errorf("%w: do need a command name", ErrWrongArgCount))
+		return cmd, nil
+	}
+	if _, ok := args[0].(string); !ok {
+		cmd.SetError(fmt.Errorf("%w: command name should be string", ErrInvalidArgument))
+		return cmd, nil
+	}
+	name := cmd.Name()
+	command := c.lookupCommand(name)
+	if command == nil {
+		cmd.SetError(unknownCommand(name))
+		return cmd, nil
+	}
+	err := command.spec.checkArity(name, len(args))
+	if err != nil {
+		cmd.SetError(err)
+		return cmd, nil
+	}
+	converted, err := convertArgs(name, command.args, args[1:])
+	if err != nil {
+		cmd.SetError(err)
+		return cmd, nil
+	}
+	cmd._args = append([]interface{}{name}, converted...)
+	return cmd, command
+}
+
+// Do runs the registered command args[0] with the rest arguments, like
+// go-redis. Strings, []byte and integers are converted to the argument types
+// of the built-in commands, so Do(ctx, "set", "key", "value") is Set("key", []byte("value"))
+func (s *MemCache) Do(ctx context.Context, args ...interface{}) *Cmd {
+	if err := ctx.Err(); err != nil {
+		cmd := NewCmd(args...)
+		cmd.SetError(err)
+		return cmd
+	}
+	cmd, _ := s.prepareCmd(args)
+	if cmd.Err() != nil {
+		// counted like the errors of doWithTransaction
+		s.l.Lock()
+		s.stats.recordError(cmd.Err())
+		s.l.Unlock()
+		return cmd
+	}
+	s.doWithContext(ctx, cmd)
+	return cmd
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errors returned by commands, use errors.Is to check them
+var (
+	ErrWrongType       = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrWrongArgCount   = errors.New("wrong number of arguments")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrLimitExceeded   = errors.New("limit exceeded")
+	ErrNoSuchKey       = errors.New("no such key")
+	ErrClosed          = errors.New("mem-cache is closed")
+	ErrUnknownCommand  = errors.New("unknown command")
+	ErrCommandPanic    = errors.New("command panic")
+	ErrSlowSubscriber  = errors.New("subscriber is too slow to receive messages")
+	ErrNoPermission    = errors.New("NOPERM")
+	ErrWrongPass       = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+)
+
+// Nil is returned by Result() when the key or field does not exist,
+// like go-redis, so an absent value can be told from an empty one
+var Nil = errors.New("mem-cache: nil")
+
+// LimitError is returned when the keys count or the used memory limit
+// is reached and nothing can be evicted, it matches ErrLimitExceeded
+type LimitError struct {
+	Name  string
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit: %d", e.Name, e.Limit)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// PanicError is returned when a command panics, the data lock is released
+// but the data changed by the command before the panic is kept.
+// It matches ErrCommandPanic
+type PanicError struct {
+	Name  string
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("command %s panic: %v", e.Name, e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrCommandPanic
+}
@@ -0,0 +1,90 @@
+package cache
+
+import "fmt"
+
+// EvictionPolicy decides which key is deleted when a limit is reached
+type EvictionPolicy string
+
+const (
+	NoEviction     EvictionPolicy = "noeviction"
+	AllKeysLRU     EvictionPolicy = "allkeys-lru"
+	AllKeysLFU     EvictionPolicy = "allkeys-lfu"
+	AllKeysRandom  EvictionPolicy = "allkeys-random"
+	VolatileLRU    EvictionPolicy = "volatile-lru"
+	VolatileLFU    EvictionPolicy = "volatile-lfu"
+	VolatileTTL    EvictionPolicy = "volatile-ttl"
+	VolatileRandom EvictionPolicy = "volatile-random"
+)
+
+// DefaultMaxMemorySamples is used when CacheConf.MaxMemorySamples is not set
+const DefaultMaxMemorySamples = 5
+
+func checkEvictionPolicy(policy EvictionPolicy) error {
+	switch policy {
+	case "", NoEviction, AllKeysLRU, AllKeysLFU, AllKeysRandom,
+		VolatileLRU, VolatileLFU, VolatileTTL, VolatileRandom:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown eviction policy: %s", ErrInvalidArgument, policy)
+}
+
+// evict deletes one key chosen by the eviction policy from a sample of keys,
+// return false when policy is noeviction or there is no key can be evicted
+func (db *MemCacheDB) evict() bool {
+	var candidates []string
+	switch db.policy {
+	case AllKeysLRU, AllKeysLFU, AllKeysRandom:
+		candidates = db.sampleKeys()
+	case VolatileLRU, VolatileLFU, VolatileTTL, VolatileRandom:
+		candidates = db.sampleVolatileKeys()
+	default:
+		return false
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+	best := candidates[0]
+	for _, key := range candidates[1:] {
+		switch db.policy {
+		case AllKeysLRU, VolatileLRU:
+			if db.access[key].lru.Before(db.access[best].lru) {
+				best = key
+			}
+		case AllKeysLFU, VolatileLFU:
+			if db.lfuDecr(db.access[key]) < db.lfuDecr(db.access[best]) {
+				best = key
+			}
+		case VolatileTTL:
+			if db.ttl[key].Before(db.ttl[best]) {
+				best = key
+			}
+		}
+	}
+	db.delKey(best, true)
+	db.evicted++
+	db.notify(notifyEvicted, "evicted", best)
+	return true
+}
+
+// map iteration starts at a random position, so the first keys are a random sample
+func (db *MemCacheDB) sampleKeys() []string {
+	sample := make([]string, 0, db.samples)
+	for key := range db.keys {
+		if len(sample) >= db.samples {
+			break
+		}
+		sample = append(sample, key)
+	}
+	return sample
+}
+
+func (db *MemCacheDB) sampleVolatileKeys() []string {
+	sample := make([]string, 0, db.samples)
+	for key := range db.ttl {
+		if len(sample) >= db.samples {
+			break
+		}
+		sample = append(sample, key)
+	}
+	return sample
+}
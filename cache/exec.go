@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// register cmd when add a operate, exec is called by the EX writes only
+func commandExec() {
+	registerInternal("exec", CommandSpec{
+		Arity:   2,
+		Flags:   FlagWrite,
+		Group:   "transactions",
+		Summary: "Run commands atomically",
+	}, (*MemCacheDB).exec)
+}
+
+// commands of exec run one by one and stop at the first error, the result
+// of exec is the count of commands run
+func (db *MemCacheDB) exec(result IResult) {
+	if len(result.Args()) != 1 {
+		result.SetError(fmt.Errorf("%w: exec need 1 argument", ErrWrongArgCount))
+		return
+	}
+	cmds, ok := result.Args()[0].([]IResult)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: exec argument 1 should be []IResult", ErrInvalidArgument))
+		return
+	}
+	n := 0
+	// a panic of a command is the error of exec
+	defer func() {
+		result.SetVal(n)
+	}()
+	for _, cmd := range cmds {
+		db.core.lookupCommand(cmd.Name()).fn(db, cmd)
+		n++
+		if cmd.Err() != nil {
+			return
+		}
+	}
+}
+
+// execCommands returns the commands of an exec result
+func execCommands(r IResult) ([]IResult, bool) {
+	if r.Name() != "exec" || len(r.Args()) != 1 {
+		return nil, false
+	}
+	cmds, ok := r.Args()[0].([]IResult)
+	return cmds, ok
+}
+
+// formatExec formats the commands of exec for the slow log and the monitors
+func formatExec(cmds []IResult) string {
+	parts := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		args := []string{cmd.Name()}
+		for _, arg := range cmd.Args() {
+			args = append(args, formatArg(arg))
+		}
+		parts[i] = strings.Join(args, " ")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// writeEX runs the write command r and sets the ttl of seconds on key in one
+// exec, so the key is never seen without the ttl. The ttl is not set when r
+// fails, an error of exec or of the expire is the error of r.
+func (s *MemCache) writeEX(ctx context.Context, r IResult, key string, seconds int) {
+	if seconds <= 0 {
+		r.SetError(fmt.Errorf("%w: expire seconds can't <= 0, should be integer in [1, 2147483647]", ErrInvalidArgument))
+		return
+	}
+	expire := NewIntResult("expire", key, seconds)
+	exec := NewIntResult("exec", []IResult{r, expire})
+	s.doWithContext(ctx, exec)
+	if err := exec.Err(); err != nil {
+		r.SetError(err)
+	} else if err := expire.Err(); err != nil && r.Err() == nil {
+		r.SetError(err)
+	}
+}
@@ -0,0 +1,91 @@
+package cache
+
+// globMatch reports whether s matches the glob pattern like redis KEYS and
+// PSUBSCRIBE, * any string, ? any byte, [abc] [^abc] [a-z] a byte of the
+// class and \ escapes the next byte
+func globMatch(pattern, s string) bool {
+	// every token but * matches one byte, so on a mismatch only the last *
+	// is retried with one more byte of s, this keeps the match O(len(pattern)*len(s))
+	var starPattern, starS string
+	star := false
+	for {
+		if len(pattern) > 0 {
+			switch pattern[0] {
+			case '*':
+				for len(pattern) > 0 && pattern[0] == '*' {
+					pattern = pattern[1:]
+				}
+				if len(pattern) == 0 {
+					return true
+				}
+				starPattern, starS, star = pattern, s, true
+				continue
+			case '?':
+				if len(s) > 0 {
+					pattern, s = pattern[1:], s[1:]
+					continue
+				}
+			case '[':
+				if len(s) > 0 {
+					if rest, ok := matchClass(pattern[1:], s[0]); ok {
+						pattern, s = rest, s[1:]
+						continue
+					}
+				}
+			default:
+				literal := pattern
+				if literal[0] == '\\' && len(literal) > 1 {
+					literal = literal[1:]
+				}
+				if len(s) > 0 && s[0] == literal[0] {
+					pattern, s = literal[1:], s[1:]
+					continue
+				}
+			}
+		} else if len(s) == 0 {
+			return true
+		}
+		if !star || len(starS) == 0 {
+			return false
+		}
+		starS = starS[1:]
+		pattern, s = starPattern, starS
+	}
+}
+
+// matchClass matches c with the class after [, returns the pattern after ]
+func matchClass(pattern string, c byte) (string, bool) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			if pattern[1] == c {
+				match = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				match = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				match = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// skip ]
+		pattern = pattern[1:]
+	}
+	return pattern, match != not
+}
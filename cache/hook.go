@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Hook runs around every command dispatched by doWithTransaction, like the
+// hooks of go-redis. Hooks are called without the data lock, so they can
+// call the MemCache.
+type Hook interface {
+	// BeforeProcess is called before the command in the order of
+	// CacheConf.Hooks, the returned context is passed to the next hooks and
+	// the command, an error rejects the command and becomes its error
+	BeforeProcess(ctx context.Context, r IResult) (context.Context, error)
+	// AfterProcess is called in the reverse order for every hook whose
+	// BeforeProcess succeeded, d includes the time waiting for the data lock,
+	// the error is r.Err()
+	AfterProcess(ctx context.Context, r IResult, d time.Duration)
+}
+
+// HookFuncs implements Hook with functions, a nil function does nothing
+type HookFuncs struct {
+	Before func(ctx context.Context, r IResult) (context.Context, error)
+	After  func(ctx context.Context, r IResult, d time.Duration)
+}
+
+func (h HookFuncs) BeforeProcess(ctx context.Context, r IResult) (context.Context, error) {
+	if h.Before == nil {
+		return ctx, nil
+	}
+	return h.Before(ctx, r)
+}
+
+func (h HookFuncs) AfterProcess(ctx context.Context, r IResult, d time.Duration) {
+	if h.After != nil {
+		h.After(ctx, r, d)
+	}
+}
+
+// processWithHooks calls the hooks around run
+func (c *core) processWithHooks(ctx context.Context, r IResult, run func(ctx context.Context, r IResult)) {
+	start := time.Now()
+	n := 0
+	for ; n < len(c.hooks); n++ {
+		next, err := c.hooks[n].BeforeProcess(ctx, r)
+		if err != nil {
+			r.SetError(err)
+			break
+		}
+		if next != nil {
+			ctx = next
+		}
+	}
+	if n == len(c.hooks) {
+		run(ctx, r)
+	}
+	d := time.Since(start)
+	for i := n - 1; i >= 0; i-- {
+		c.hooks[i].AfterProcess(ctx, r, d)
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// NumLatencyBuckets is the count of the upper bounds of a Histogram
+const NumLatencyBuckets = 16
+
+// LatencyBuckets are the upper bounds of the buckets of a Histogram
+var LatencyBuckets = [NumLatencyBuckets]time.Duration{
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Histogram counts durations by LatencyBuckets, Counts[i] is the count of
+// durations in (LatencyBuckets[i-1], LatencyBuckets[i]], the last one is the
+// count of durations greater than every bucket
+type Histogram struct {
+	Counts [NumLatencyBuckets + 1]int64
+	Count  int64
+	Sum    time.Duration
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < NumLatencyBuckets && d > LatencyBuckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Cumulative returns the count of durations less than or equal to every
+// bucket, like the buckets of a prometheus histogram
+func (h *Histogram) Cumulative() [NumLatencyBuckets]int64 {
+	var cumulative [NumLatencyBuckets]int64
+	var n int64
+	for i := 0; i < NumLatencyBuckets; i++ {
+		n += h.Counts[i]
+		cumulative[i] = n
+	}
+	return cumulative
+}
+
+// types of the errors counted by Stats.Errors
+const (
+	ErrorTypeWrongType       = "wrongtype"
+	ErrorTypeWrongArgCount   = "wrong_arg_count"
+	ErrorTypeInvalidArgument = "invalid_argument"
+	ErrorTypeLimitExceeded   = "limit_exceeded"
+	ErrorTypeClosed          = "closed"
+	ErrorTypeUnknownCommand  = "unknown_command"
+	ErrorTypePanic           = "panic"
+	ErrorTypeNoPermission    = "noperm"
+	ErrorTypeOther           = "other"
+)
+
+// errorType returns the type of err for Stats.Errors
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, ErrWrongType):
+		return ErrorTypeWrongType
+	case errors.Is(err, ErrWrongArgCount):
+		return ErrorTypeWrongArgCount
+	case errors.Is(err, ErrInvalidArgument):
+		return ErrorTypeInvalidArgument
+	case errors.Is(err, ErrLimitExceeded):
+		return ErrorTypeLimitExceeded
+	case errors.Is(err, ErrClosed):
+		return ErrorTypeClosed
+	case errors.Is(err, ErrUnknownCommand):
+		return ErrorTypeUnknownCommand
+	case errors.Is(err, ErrCommandPanic):
+		return ErrorTypePanic
+	case errors.Is(err, ErrNoPermission):
+		return ErrorTypeNoPermission
+	}
+	return ErrorTypeOther
+}
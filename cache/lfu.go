@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// same as redis lfu-log-factor and lfu-decay-time
+const (
+	DefaultLfuLogFactor = 10
+	DefaultLfuDecayTime = 1
+)
+
+// LfuNeverDecay is the LfuDecayTime of a counter that never decays, like
+// lfu-decay-time 0 of redis, since 0 is DefaultLfuDecayTime in CacheConf
+const LfuNeverDecay = -1
+
+// counter of a new key, so it is not evicted before it has a chance to be used
+const lfuInitVal = 5
+
+// access info of a key, used by eviction and OBJECT
+type keyAccess struct {
+	lru  time.Time // last access time
+	freq uint8     // logarithmic access counter
+	ldt  time.Time // last decrement time of freq
+}
+
+// touch is called every time a command uses key
+func (db *MemCacheDB) touch(key string) {
+	access, ok := db.access[key]
+	if !ok {
+		access.freq = lfuInitVal
+	} else {
+		access.freq = db.lfuLogIncr(db.lfuDecr(access))
+	}
+	now := time.Now()
+	access.lru = now
+	access.ldt = now
+	db.access[key] = access
+}
+
+// lfuLogIncr increments the counter with probability 1/((counter-init)*factor+1)
+func (db *MemCacheDB) lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+	baseval := float64(counter) - lfuInitVal
+	if baseval < 0 {
+		baseval = 0
+	}
+	p := 1.0 / (baseval*float64(db.lfuLogFactor) + 1)
+	if rand.Float64() < p {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecr returns the counter decremented by the idle periods since last decrement
+func (db *MemCacheDB) lfuDecr(access keyAccess) uint8 {
+	if db.lfuDecayTime <= 0 {
+		return access.freq
+	}
+	periods := int(time.Since(access.ldt)/time.Minute) / db.lfuDecayTime
+	if periods >= int(access.freq) {
+		return 0
+	}
+	return access.freq - uint8(periods)
+}
+
+// register cmd when add a operate
+func commandObject() {
+	register("object", CommandSpec{
+		Arity:    3,
+		Flags:    FlagReadOnly,
+		FirstKey: 2,
+		LastKey:  2,
+		KeyStep:  1,
+		Group:    "generic",
+		Summary:  "Inspect the LFU frequency or idle time of a key",
+	}, (*MemCacheDB).object, argString, argString)
+}
+
+// OBJECT FREQ|IDLETIME key, key is not touched
+func (db *MemCacheDB) object(result IResult) {
+	if len(result.Args()) != 2 {
+		result.SetError(fmt.Errorf("%w: object need 2 argument", ErrWrongArgCount))
+		return
+	}
+	arg0, ok := result.Args()[0].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: object argument 1 should be string", ErrInvalidArgument))
+		return
+	}
+	arg1, ok := result.Args()[1].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: object argument 2 should be string", ErrInvalidArgument))
+		return
+	}
+	err := db.expireIfNeeded(arg1)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if db.keys[arg1] == DEFAULT {
+		result.SetError(ErrNoSuchKey)
+		return
+	}
+	access := db.access[arg1]
+	switch strings.ToLower(arg0) {
+	case "freq":
+		result.SetVal(int(db.lfuDecr(access)))
+	case "idletime":
+		result.SetVal(int(time.Since(access.lru) / time.Second))
+	default:
+		result.SetError(fmt.Errorf("%w: object argument 1 should be freq or idletime", ErrInvalidArgument))
+	}
+}
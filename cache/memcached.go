@@ -0,0 +1,378 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// StoreMode is the memcached storage command of MCStore
+type StoreMode string
+
+const (
+	StoreSet     StoreMode = "set"
+	StoreAdd     StoreMode = "add"
+	StoreReplace StoreMode = "replace"
+	StoreAppend  StoreMode = "append"
+	StorePrepend StoreMode = "prepend"
+	StoreCas     StoreMode = "cas"
+)
+
+// result of MCStore
+const (
+	Stored = iota
+	NotStored
+	Exists
+	NotFound
+)
+
+type stringMeta struct {
+	flags uint32
+	cas   uint64
+}
+
+// register cmd when add a operate, arguments are Go values of the memcached
+// package so the commands are hidden from COMMAND
+func commandMemcached() {
+	registerInternal("mcget", CommandSpec{
+		Arity:    2,
+		Flags:    FlagReadOnly | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "memcached",
+		Summary:  "Get string items with flags and cas",
+	}, (*MemCacheDB).mcGet)
+	registerInternal("mcstore", CommandSpec{
+		Arity:   4,
+		Flags:   FlagWrite | FlagDenyOOM,
+		Group:   "memcached",
+		Summary: "Store a string item by a memcached storage mode",
+	}, (*MemCacheDB).mcStore)
+	registerInternal("mcincr", CommandSpec{
+		Arity:    4,
+		Flags:    FlagWrite | FlagDenyOOM | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "memcached",
+		Summary:  "Increment or decrement a decimal string value",
+	}, (*MemCacheDB).mcIncr)
+	registerInternal("mctouch", CommandSpec{
+		Arity:    3,
+		Flags:    FlagWrite | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "memcached",
+		Summary:  "Update the expire time of a string key",
+	}, (*MemCacheDB).mcTouch)
+	registerInternal("mcdelete", CommandSpec{
+		Arity:    2,
+		Flags:    FlagWrite | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "memcached",
+		Summary:  "Delete a string key",
+	}, (*MemCacheDB).mcDelete)
+	registerInternal("mcflush", CommandSpec{
+		Arity:   1,
+		Flags:   FlagWrite,
+		Group:   "memcached",
+		Summary: "Delete all string keys",
+	}, (*MemCacheDB).mcFlush)
+}
+
+// meta returns the flags and cas of a string key, a new cas is given
+// when the value was written by set or has never been read by gets
+func (db *MemCacheDB) meta(key string) stringMeta {
+	meta, ok := db.smeta[key]
+	if !ok {
+		db.casSeq++
+		meta.cas = db.casSeq
+		db.smeta[key] = meta
+	}
+	return meta
+}
+
+// storeString writes a string value with new flags and cas
+func (db *MemCacheDB) storeString(key string, value []byte, flags uint32) error {
+	_, err := db.addKey(key, STRING)
+	if err != nil {
+		return err
+	}
+	db.incrMem(key, int64(len(value)-len(db.s[key])))
+	db.s[key] = value
+	db.casSeq++
+	db.smeta[key] = stringMeta{flags: flags, cas: db.casSeq}
+	return nil
+}
+
+// setExpireAt sets the ttl of key, zero time removes the ttl,
+// time in the past deletes key at once
+func (db *MemCacheDB) setExpireAt(key string, expireAt time.Time) {
+	_, hasTTL := db.ttl[key]
+	switch {
+	case expireAt.IsZero():
+		if hasTTL {
+			delete(db.ttl, key)
+			db.incrMem(key, -ttlOverhead)
+		}
+	case !expireAt.After(time.Now()):
+		db.delKey(key, true)
+		db.notify(notifyGeneric, "del", key)
+	default:
+		if !hasTTL {
+			db.incrMem(key, ttlOverhead)
+		}
+		db.ttl[key] = expireAt
+		db.notify(notifyGeneric, "expire", key)
+	}
+}
+
+// keys can be multi, return items of keys exist, keys not exist or not string are skipped
+func (db *MemCacheDB) mcGet(result IResult) {
+	if len(result.Args()) != 1 {
+		result.SetError(fmt.Errorf("%w: mcget need 1 argument", ErrWrongArgCount))
+		return
+	}
+	keys, ok := result.Args()[0].([]string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcget keys should be []string", ErrInvalidArgument))
+		return
+	}
+	items := make([]*Item, 0, len(keys))
+	for _, key := range keys {
+		err := db.doBeforeProcess(key, DEFAULT)
+		if err != nil {
+			result.SetError(err)
+			return
+		}
+		if db.keys[key] != STRING {
+			continue
+		}
+		meta := db.meta(key)
+		items = append(items, &Item{Key: key, Value: db.s[key], Flags: meta.flags, Cas: meta.cas})
+	}
+	result.SetVal(items)
+}
+
+// mode, item and expire time, return Stored, NotStored, Exists or NotFound
+func (db *MemCacheDB) mcStore(result IResult) {
+	if len(result.Args()) != 3 {
+		result.SetError(fmt.Errorf("%w: mcstore need 3 argument", ErrWrongArgCount))
+		return
+	}
+	mode, ok := result.Args()[0].(StoreMode)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcstore argument 1 should be StoreMode", ErrInvalidArgument))
+		return
+	}
+	item, ok := result.Args()[1].(*Item)
+	if !ok || item == nil {
+		result.SetError(fmt.Errorf("%w: mcstore argument 2 should be *Item", ErrInvalidArgument))
+		return
+	}
+	expireAt, ok := result.Args()[2].(time.Time)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcstore argument 3 should be time.Time", ErrInvalidArgument))
+		return
+	}
+	err := db.doBeforeProcess(item.Key, STRING)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	exist := db.keys[item.Key] == STRING
+	value := item.Value
+	if value == nil {
+		value = []byte{}
+	}
+	flags := item.Flags
+	switch mode {
+	case StoreSet:
+	case StoreAdd:
+		if exist {
+			result.SetVal(NotStored)
+			return
+		}
+	case StoreReplace:
+		if !exist {
+			result.SetVal(NotStored)
+			return
+		}
+	case StoreAppend, StorePrepend:
+		if !exist {
+			result.SetVal(NotStored)
+			return
+		}
+		old := db.s[item.Key]
+		if mode == StoreAppend {
+			value = append(append(make([]byte, 0, len(old)+len(value)), old...), value...)
+		} else {
+			value = append(append(make([]byte, 0, len(old)+len(value)), value...), old...)
+		}
+		// append and prepend keep flags and exptime
+		flags = db.meta(item.Key).flags
+		expireAt = db.ttl[item.Key]
+	case StoreCas:
+		if !exist {
+			result.SetVal(NotFound)
+			return
+		}
+		if db.meta(item.Key).cas != item.Cas {
+			result.SetVal(Exists)
+			return
+		}
+	default:
+		result.SetError(fmt.Errorf("%w: unknown store mode %s", ErrInvalidArgument, mode))
+		return
+	}
+	err = db.checkMemory()
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	err = db.storeString(item.Key, value, flags)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if mode == StoreAppend || mode == StorePrepend {
+		db.notify(notifyString, string(mode), item.Key)
+	} else {
+		db.notify(notifyString, "set", item.Key)
+	}
+	db.setExpireAt(item.Key, expireAt)
+	result.SetVal(Stored)
+}
+
+// key, delta and decr, return the new value, key not exist return Nil.
+// Like memcached incr wraps at 2^64 and decr stops at 0
+func (db *MemCacheDB) mcIncr(result IResult) {
+	if len(result.Args()) != 3 {
+		result.SetError(fmt.Errorf("%w: mcincr need 3 argument", ErrWrongArgCount))
+		return
+	}
+	arg0, ok := result.Args()[0].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcincr argument 1 should be string", ErrInvalidArgument))
+		return
+	}
+	arg1, ok := result.Args()[1].(uint64)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcincr argument 2 should be uint64", ErrInvalidArgument))
+		return
+	}
+	arg2, ok := result.Args()[2].(bool)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcincr argument 3 should be bool", ErrInvalidArgument))
+		return
+	}
+	err := db.doBeforeProcess(arg0, STRING)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	old, ok := db.s[arg0]
+	if !ok {
+		result.SetError(Nil)
+		return
+	}
+	n, err := strconv.ParseUint(string(old), 10, 64)
+	if err != nil {
+		result.SetError(fmt.Errorf("%w: cannot increment or decrement non-numeric value", ErrInvalidArgument))
+		return
+	}
+	switch {
+	case !arg2:
+		n += arg1
+	case arg1 > n:
+		n = 0
+	default:
+		n -= arg1
+	}
+	value := []byte(strconv.FormatUint(n, 10))
+	err = db.storeString(arg0, value, db.meta(arg0).flags)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if arg2 {
+		db.notify(notifyString, "decrby", arg0)
+	} else {
+		db.notify(notifyString, "incrby", arg0)
+	}
+	result.SetVal(value)
+}
+
+// key and expire time, touched return 1, key not exist return 0
+func (db *MemCacheDB) mcTouch(result IResult) {
+	if len(result.Args()) != 2 {
+		result.SetError(fmt.Errorf("%w: mctouch need 2 argument", ErrWrongArgCount))
+		return
+	}
+	arg0, ok := result.Args()[0].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mctouch argument 1 should be string", ErrInvalidArgument))
+		return
+	}
+	arg1, ok := result.Args()[1].(time.Time)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mctouch argument 2 should be time.Time", ErrInvalidArgument))
+		return
+	}
+	err := db.doBeforeProcess(arg0, DEFAULT)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if db.keys[arg0] != STRING {
+		result.SetVal(0)
+		return
+	}
+	db.setExpireAt(arg0, arg1)
+	result.SetVal(1)
+}
+
+// key, deleted return 1, key not exist or not string return 0
+func (db *MemCacheDB) mcDelete(result IResult) {
+	if len(result.Args()) != 1 {
+		result.SetError(fmt.Errorf("%w: mcdelete need 1 argument", ErrWrongArgCount))
+		return
+	}
+	arg0, ok := result.Args()[0].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: mcdelete argument 1 should be string", ErrInvalidArgument))
+		return
+	}
+	err := db.doBeforeProcess(arg0, DEFAULT)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if db.keys[arg0] != STRING {
+		result.SetVal(0)
+		return
+	}
+	db.delKey(arg0, true)
+	db.notify(notifyGeneric, "del", arg0)
+	result.SetVal(1)
+}
+
+// return the count of deleted string keys, keys of other types are kept
+func (db *MemCacheDB) mcFlush(result IResult) {
+	if len(result.Args()) != 0 {
+		result.SetError(fmt.Errorf("%w: mcflush need 0 argument", ErrWrongArgCount))
+		return
+	}
+	n := 0
+	for key, valueType := range db.keys {
+		if valueType == STRING {
+			db.delKey(key, true)
+			n++
+		}
+	}
+	result.SetVal(n)
+}
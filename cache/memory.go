@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// estimated bytes used by go runtime besides the data itself
+const (
+	keyOverhead    = 64 // entry in keys map and string header
+	ttlOverhead    = 48 // entry in ttl map
+	stringOverhead = 24 // slice header
+	hashOverhead   = 48 // inner map header
+	fieldOverhead  = 48 // inner map entry, string and slice header
+	setOverhead    = 48 // inner map header
+	memberOverhead = 32 // inner map entry, string header and float64
+)
+
+func valueOverhead(valueType ValueType) int64 {
+	switch valueType {
+	case STRING:
+		return stringOverhead
+	case HASH:
+		return hashOverhead
+	case Set:
+		return setOverhead
+	}
+	return 0
+}
+
+func fieldSize(field string, value []byte) int64 {
+	return int64(len(field)+len(value)) + fieldOverhead
+}
+
+func memberSize(member string) int64 {
+	return int64(len(member)) + memberOverhead
+}
+
+// incrMem changes the estimated size of key and of the whole db
+func (db *MemCacheDB) incrMem(key string, delta int64) {
+	db.mem[key] += delta
+	db.used += delta
+}
+
+// checkMemory is called by commands that grow data, like redis keys are
+// evicted while the used memory is over the limit, the command is rejected
+// when nothing can be evicted
+func (db *MemCacheDB) checkMemory() error {
+	for db.maxMemory > 0 && db.used >= db.maxMemory {
+		if !db.evict() {
+			return &LimitError{Name: "used memory", Limit: db.maxMemory}
+		}
+	}
+	return nil
+}
+
+// register cmd when add a operate
+func commandMemory() {
+	register("memory", CommandSpec{
+		Arity:    3,
+		Flags:    FlagReadOnly,
+		FirstKey: 2,
+		LastKey:  2,
+		KeyStep:  1,
+		Group:    "server",
+		Summary:  "Estimate the memory usage of a key",
+	}, (*MemCacheDB).memory, argString, argString)
+}
+
+// MEMORY USAGE key, return estimated bytes of key, key not exist return Nil,
+// key is not touched
+func (db *MemCacheDB) memory(result IResult) {
+	if len(result.Args()) != 2 {
+		result.SetError(fmt.Errorf("%w: memory need 2 argument", ErrWrongArgCount))
+		return
+	}
+	arg0, ok := result.Args()[0].(string)
+	if !ok || strings.ToLower(arg0) != "usage" {
+		result.SetError(fmt.Errorf("%w: memory argument 1 should be usage", ErrInvalidArgument))
+		return
+	}
+	arg1, ok := result.Args()[1].(string)
+	if !ok {
+		result.SetError(fmt.Errorf("%w: memory argument 2 should be string", ErrInvalidArgument))
+		return
+	}
+	err := db.expireIfNeeded(arg1)
+	if err != nil {
+		result.SetError(err)
+		return
+	}
+	if db.keys[arg1] == DEFAULT {
+		result.SetError(Nil)
+		return
+	}
+	result.SetVal(int(db.mem[arg1]))
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultMonitorBufferSize is used when CacheConf.MonitorBufferSize is not set
+const DefaultMonitorBufferSize = 1000
+
+// MonitorEvent is a command dispatched to a database, received from
+// Monitor.Channel
+type MonitorEvent struct {
+	Time   time.Time
+	DB     int
+	Client ClientInfo
+	// Args including the command name
+	Args []string
+}
+
+// String formats the event like the output of redis MONITOR
+func (ev *MonitorEvent) String() string {
+	b := make([]byte, 0, 64)
+	b = append(b, fmt.Sprintf("%d.%06d [%d", ev.Time.Unix(), ev.Time.Nanosecond()/1000, ev.DB)...)
+	if ev.Client.Addr != "" {
+		b = append(b, ' ')
+		b = append(b, ev.Client.Addr...)
+	}
+	b = append(b, ']')
+	for _, arg := range ev.Args {
+		b = append(b, ' ')
+		b = appendQuoted(b, arg)
+	}
+	return string(b)
+}
+
+// appendQuoted quotes s like redis, non printable bytes are escaped as \xhh
+func appendQuoted(b []byte, s string) []byte {
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '"':
+			b = append(b, '\\', c)
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\t':
+			b = append(b, '\\', 't')
+		default:
+			if c < 0x20 || c >= 0x7f {
+				b = append(b, fmt.Sprintf("\\x%02x", c)...)
+			} else {
+				b = append(b, c)
+			}
+		}
+	}
+	return append(b, '"')
+}
+
+// Monitor receives every command dispatched by the MemCache, Channel is
+// closed by Close or when the MemCache is closed
+type Monitor struct {
+	c  *core
+	ch chan *MonitorEvent
+	// guarded by the data lock
+	closed  bool
+	dropped int64
+}
+
+// Monitor starts receiving the commands of all databases, the events are
+// dropped when Channel is full so a slow monitor never blocks the commands
+func (s *MemCache) Monitor() *Monitor {
+	m := &Monitor{c: s.core, ch: make(chan *MonitorEvent, s.monitorBufferSize)}
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closed {
+		m.closed = true
+		close(m.ch)
+		return m
+	}
+	s.monitors[m] = struct{}{}
+	return m
+}
+
+// Channel returns the channel of events
+func (m *Monitor) Channel() <-chan *MonitorEvent {
+	return m.ch
+}
+
+// Dropped returns the count of events dropped because Channel was full
+func (m *Monitor) Dropped() int64 {
+	m.c.l.Lock()
+	defer m.c.l.Unlock()
+	return m.dropped
+}
+
+// Close stops receiving and closes Channel
+func (m *Monitor) Close() error {
+	m.c.l.Lock()
+	defer m.c.l.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.c.closeMonitor(m)
+	return nil
+}
+
+// closeMonitor closes m, the data lock must be held
+func (c *core) closeMonitor(m *Monitor) {
+	delete(c.monitors, m)
+	m.closed = true
+	close(m.ch)
+}
+
+// feedMonitors sends the command of r to the monitors, the data lock must
+// be held, nothing is formatted when nobody is monitoring
+func (c *core) feedMonitors(ctx context.Context, db int, r IResult) {
+	if len(c.monitors) == 0 {
+		return
+	}
+	client, _ := ClientInfoFromContext(ctx)
+	all := append([]interface{}{r.Name()}, r.Args()...)
+	ev := &MonitorEvent{
+		Time:   time.Now(),
+		DB:     db,
+		Client: client,
+		Args:   make([]string, len(all)),
+	}
+	for i, arg := range all {
+		ev.Args[i] = formatArg(arg)
+	}
+	for m := range c.monitors {
+		select {
+		case m.ch <- ev:
+		default:
+			m.dropped++
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// classes of notify-keyspace-events
+const (
+	notifyKeyspace = 1 << iota // K
+	notifyKeyevent             // E
+	notifyGeneric              // g
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZset                 // z
+	notifyExpired              // x
+	notifyEvicted              // e
+	notifyAll      = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZset | notifyExpired | notifyEvicted
+)
+
+// parseNotifyKeyspaceEvents parses the class mask like notify-keyspace-events
+// of redis, for example "KEA" or "Ex"
+func parseNotifyKeyspaceEvents(classes string) (int, error) {
+	flags := 0
+	for _, c := range classes {
+		switch c {
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'l':
+			flags |= notifyList
+		case 's':
+			flags |= notifySet
+		case 'h':
+			flags |= notifyHash
+		case 'z':
+			flags |= notifyZset
+		case 'x':
+			flags |= notifyExpired
+		case 'e':
+			flags |= notifyEvicted
+		case 'A':
+			flags |= notifyAll
+		default:
+			return 0, fmt.Errorf("%w: invalid notify keyspace events class '%c'", ErrInvalidArgument, c)
+		}
+	}
+	return flags, nil
+}
+
+// KeyspaceEvent is a change of a key, Event is the command like set, del,
+// hset, or expired and evicted
+type KeyspaceEvent struct {
+	DB    int
+	Event string
+	Key   string
+}
+
+// notify queues the event of key when its class is enabled, events are
+// delivered after the data lock is released
+func (db *MemCacheDB) notify(class int, event, key string) {
+	if db.core.notifyFlags&class == 0 {
+		return
+	}
+	db.core.events = append(db.core.events, KeyspaceEvent{DB: db.id, Event: event, Key: key})
+}
+
+// takeEvents returns the queued events, the data lock must be held
+func (c *core) takeEvents() []KeyspaceEvent {
+	events := c.events
+	c.events = nil
+	return events
+}
+
+// deliverEvents publishes events to __keyspace@<db>__:<key> with K and to
+// __keyevent@<db>__:<event> with E, and calls CacheConf.OnKeyspaceEvent
+func (c *core) deliverEvents(events []KeyspaceEvent) {
+	for _, ev := range events {
+		db := strconv.Itoa(ev.DB)
+		if c.notifyFlags&notifyKeyspace != 0 {
+			c.ps.publish("__keyspace@"+db+"__:"+ev.Key, []byte(ev.Event))
+		}
+		if c.notifyFlags&notifyKeyevent != 0 {
+			c.ps.publish("__keyevent@"+db+"__:"+ev.Event, []byte(ev.Key))
+		}
+		if c.onEvent != nil {
+			c.onEvent(ev)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultPubSubBufferSize is used when CacheConf.PubSubBufferSize is not set
+const DefaultPubSubBufferSize = 100
+
+// SlowSubscriberPolicy decides what happens when the channel of a
+// subscriber is full, like client-output-buffer-limit pubsub of redis
+type SlowSubscriberPolicy string
+
+const (
+	// DropMessage drops the message for the slow subscriber, see PubSub.Dropped
+	DropMessage SlowSubscriberPolicy = "drop"
+	// DisconnectSubscriber closes the slow subscriber with ErrSlowSubscriber
+	DisconnectSubscriber SlowSubscriberPolicy = "disconnect"
+)
+
+func checkSlowSubscriberPolicy(policy SlowSubscriberPolicy) error {
+	switch policy {
+	case "", DropMessage, DisconnectSubscriber:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown slow subscriber policy: %s", ErrInvalidArgument, policy)
+}
+
+// Message is received from PubSub.Channel, Pattern is set when the
+// message matches a pattern subscription
+type Message struct {
+	Channel string
+	Pattern string
+	Payload []byte
+}
+
+// pubsub is shared by all databases like redis, it has its own lock
+// so publishing never waits for the data lock
+type pubsub struct {
+	mu         sync.Mutex
+	channels   map[string]map[*PubSub]struct{}
+	patterns   map[string]map[*PubSub]struct{}
+	bufferSize int
+	policy     SlowSubscriberPolicy
+	closed     bool
+}
+
+func newPubSub(conf *CacheConf) *pubsub {
+	ps := &pubsub{
+		channels:   make(map[string]map[*PubSub]struct{}),
+		patterns:   make(map[string]map[*PubSub]struct{}),
+		bufferSize: conf.PubSubBufferSize,
+		policy:     conf.PubSubSlowPolicy,
+	}
+	if ps.bufferSize <= 0 {
+		ps.bufferSize = DefaultPubSubBufferSize
+	}
+	if ps.policy == "" {
+		ps.policy = DropMessage
+	}
+	return ps
+}
+
+// PubSub is the subscriptions of a subscriber, messages are received from
+// Channel, which is closed by Close or when the subscriber is disconnected
+type PubSub struct {
+	ps *pubsub
+	ch chan *Message
+	// guarded by ps.mu
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	closed   bool
+	err      error
+	dropped  int64
+}
+
+func (ps *pubsub) newSubscriber() *PubSub {
+	return &PubSub{
+		ps:       ps,
+		ch:       make(chan *Message, ps.bufferSize),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+func (ps *pubsub) subscribe(subs map[string]map[*PubSub]struct{}, names map[string]struct{}, p *PubSub, name string) {
+	if subs[name] == nil {
+		subs[name] = make(map[*PubSub]struct{})
+	}
+	subs[name][p] = struct{}{}
+	names[name] = struct{}{}
+}
+
+func (ps *pubsub) unsubscribe(subs map[string]map[*PubSub]struct{}, names map[string]struct{}, p *PubSub, name string) {
+	delete(subs[name], p)
+	if len(subs[name]) == 0 {
+		delete(subs, name)
+	}
+	delete(names, name)
+}
+
+// closeSubscriber removes all subscriptions of p and closes its channel,
+// the lock must be held
+func (ps *pubsub) closeSubscriber(p *PubSub, err error) {
+	if p.closed {
+		return
+	}
+	for name := range p.channels {
+		ps.unsubscribe(ps.channels, p.channels, p, name)
+	}
+	for name := range p.patterns {
+		ps.unsubscribe(ps.patterns, p.patterns, p, name)
+	}
+	p.closed = true
+	p.err = err
+	close(p.ch)
+}
+
+// deliver sends msg without blocking, the lock must be held
+func (ps *pubsub) deliver(p *PubSub, msg *Message) {
+	if p.closed {
+		return
+	}
+	select {
+	case p.ch <- msg:
+		return
+	default:
+	}
+	if ps.policy == DisconnectSubscriber {
+		ps.closeSubscriber(p, ErrSlowSubscriber)
+		return
+	}
+	p.dropped++
+}
+
+func (ps *pubsub) publish(channel string, payload []byte) (int, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed {
+		return 0, ErrClosed
+	}
+	receivers := 0
+	for p := range ps.channels[channel] {
+		ps.deliver(p, &Message{Channel: channel, Payload: payload})
+		receivers++
+	}
+	for pattern, subs := range ps.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		for p := range subs {
+			ps.deliver(p, &Message{Channel: channel, Pattern: pattern, Payload: payload})
+			receivers++
+		}
+	}
+	return receivers, nil
+}
+
+// close disconnects every subscriber with ErrClosed
+func (ps *pubsub) close() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.closed = true
+	for _, subs := range []map[string]map[*PubSub]struct{}{ps.channels, ps.patterns} {
+		for _, set := range subs {
+			for p := range set {
+				ps.closeSubscriber(p, ErrClosed)
+			}
+		}
+	}
+}
+
+// Channel returns the channel of messages
+func (p *PubSub) Channel() <-chan *Message {
+	return p.ch
+}
+
+// Subscribe subscribes to more channels
+func (p *PubSub) Subscribe(channels ...string) error {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	if p.closed {
+		return p.closedErr()
+	}
+	for _, channel := range channels {
+		p.ps.subscribe(p.ps.channels, p.channels, p, channel)
+	}
+	return nil
+}
+
+// PSubscribe subscribes to more glob patterns
+func (p *PubSub) PSubscribe(patterns ...string) error {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	if p.closed {
+		return p.closedErr()
+	}
+	for _, pattern := range patterns {
+		p.ps.subscribe(p.ps.patterns, p.patterns, p, pattern)
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes from channels, from all channels if none is given
+func (p *PubSub) Unsubscribe(channels ...string) error {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	if p.closed {
+		return p.closedErr()
+	}
+	if len(channels) == 0 {
+		for channel := range p.channels {
+			channels = append(channels, channel)
+		}
+	}
+	for _, channel := range channels {
+		p.ps.unsubscribe(p.ps.channels, p.channels, p, channel)
+	}
+	return nil
+}
+
+// PUnsubscribe unsubscribes from patterns, from all patterns if none is given
+func (p *PubSub) PUnsubscribe(patterns ...string) error {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	if p.closed {
+		return p.closedErr()
+	}
+	if len(patterns) == 0 {
+		for pattern := range p.patterns {
+			patterns = append(patterns, pattern)
+		}
+	}
+	for _, pattern := range patterns {
+		p.ps.unsubscribe(p.ps.patterns, p.patterns, p, pattern)
+	}
+	return nil
+}
+
+// Channels returns the subscribed channels sorted by name
+func (p *PubSub) Channels() []string {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	return sortedNames(p.channels)
+}
+
+// Patterns returns the subscribed patterns sorted by name
+func (p *PubSub) Patterns() []string {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	return sortedNames(p.patterns)
+}
+
+func sortedNames(set map[string]struct{}) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Subscriptions returns the count of subscribed channels and patterns
+func (p *PubSub) Subscriptions() int {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	return len(p.channels) + len(p.patterns)
+}
+
+// Dropped returns the count of messages dropped because Channel was full
+func (p *PubSub) Dropped() int64 {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	return p.dropped
+}
+
+// Err returns why the subscriber was closed, ErrSlowSubscriber or ErrClosed,
+// nil if it is open or closed by Close
+func (p *PubSub) Err() error {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	return p.err
+}
+
+func (p *PubSub) closedErr() error {
+	if p.err != nil {
+		return p.err
+	}
+	return ErrClosed
+}
+
+// Close unsubscribes from everything and closes Channel
+func (p *PubSub) Close() error {
+	p.ps.mu.Lock()
+	defer p.ps.mu.Unlock()
+	if p.closed {
+		return p.closedErr()
+	}
+	p.ps.closeSubscriber(p, nil)
+	return nil
+}
+
+func (ps *pubsub) channelNames(pattern string) []string {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	names := []string{}
+	for channel := range ps.channels {
+		if pattern == "" || globMatch(pattern, channel) {
+			names = append(names, channel)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (ps *pubsub) numSub(channels []string) map[string]int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	numSub := make(map[string]int, len(channels))
+	for _, channel := range channels {
+		numSub[channel] = len(ps.channels[channel])
+	}
+	return numSub
+}
+
+func (ps *pubsub) numPat() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return len(ps.patterns)
+}
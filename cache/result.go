@@ -2,6 +2,7 @@ package cache
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -115,6 +116,38 @@ func (r *BytesResult) SetVal(val interface{}) {
 	r.val = boolVal
 }
 
+// Item is a string value with its memcached flags and cas
+type Item struct {
+	Key   string
+	Value []byte
+	Flags uint32
+	Cas   uint64
+}
+
+type ItemsResult struct {
+	result
+	val []*Item
+}
+
+func NewItemsResult(args ...interface{}) *ItemsResult {
+	return &ItemsResult{
+		result: result{_args: args},
+	}
+}
+
+func (r *ItemsResult) Result() ([]*Item, error) {
+	return r.val, r.err
+}
+
+func (r *ItemsResult) SetVal(val interface{}) {
+	itemsVal, ok := val.([]*Item)
+	if !ok {
+		r.err = fmt.Errorf("%s need a %s type val", "ItemsResult", "[]*Item")
+		return
+	}
+	r.val = itemsVal
+}
+
 type IntResult struct {
 	result
 	val int
@@ -133,8 +166,232 @@ func (r *IntResult) Result() (int, error) {
 func (r *IntResult) SetVal(val interface{}) {
 	intVal, ok := val.(int)
 	if !ok {
-		r.err = fmt.Errorf("%s need a %s type val", "BytesResult", "[]byte")
+		r.err = fmt.Errorf("%s need a %s type val", "IntResult", "int")
 		return
 	}
 	r.val = intVal
 }
+
+type StringResult struct {
+	result
+	val string
+}
+
+func NewStringResult(args ...interface{}) *StringResult {
+	return &StringResult{
+		result: result{_args: args},
+	}
+}
+
+func (r *StringResult) Result() (string, error) {
+	return r.val, r.err
+}
+
+func (r *StringResult) SetVal(val interface{}) {
+	stringVal, ok := val.(string)
+	if !ok {
+		r.err = fmt.Errorf("%s need a %s type val", "StringResult", "string")
+		return
+	}
+	r.val = stringVal
+}
+
+type StringsResult struct {
+	result
+	val []string
+}
+
+func NewStringsResult(args ...interface{}) *StringsResult {
+	return &StringsResult{
+		result: result{_args: args},
+	}
+}
+
+func (r *StringsResult) Result() ([]string, error) {
+	return r.val, r.err
+}
+
+func (r *StringsResult) SetVal(val interface{}) {
+	stringsVal, ok := val.([]string)
+	if !ok {
+		r.err = fmt.Errorf("%s need a %s type val", "StringsResult", "[]string")
+		return
+	}
+	r.val = stringsVal
+}
+
+type SlowLogResult struct {
+	result
+	val []SlowLogEntry
+}
+
+func NewSlowLogResult(args ...interface{}) *SlowLogResult {
+	return &SlowLogResult{
+		result: result{_args: args},
+	}
+}
+
+func (r *SlowLogResult) Result() ([]SlowLogEntry, error) {
+	return r.val, r.err
+}
+
+func (r *SlowLogResult) SetVal(val interface{}) {
+	entriesVal, ok := val.([]SlowLogEntry)
+	if !ok {
+		r.err = fmt.Errorf("%s need a %s type val", "SlowLogResult", "[]SlowLogEntry")
+		return
+	}
+	r.val = entriesVal
+}
+
+type ACLUserResult struct {
+	result
+	val ACLUserInfo
+}
+
+func NewACLUserResult(args ...interface{}) *ACLUserResult {
+	return &ACLUserResult{
+		result: result{_args: args},
+	}
+}
+
+func (r *ACLUserResult) Result() (ACLUserInfo, error) {
+	return r.val, r.err
+}
+
+func (r *ACLUserResult) SetVal(val interface{}) {
+	userVal, ok := val.(ACLUserInfo)
+	if !ok {
+		r.err = fmt.Errorf("%s need a %s type val", "ACLUserResult", "ACLUserInfo")
+		return
+	}
+	r.val = userVal
+}
+
+type IntMapResult struct {
+	result
+	val map[string]int
+}
+
+func NewIntMapResult(args ...interface{}) *IntMapResult {
+	return &IntMapResult{
+		result: result{_args: args},
+	}
+}
+
+func (r *IntMapResult) Result() (map[string]int, error) {
+	return r.val, r.err
+}
+
+func (r *IntMapResult) SetVal(val interface{}) {
+	mapVal, ok := val.(map[string]int)
+	if !ok {
+		r.err = fmt.Errorf("%s need a %s type val", "IntMapResult", "map[string]int")
+		return
+	}
+	r.val = mapVal
+}
+
+// Cmd is the generic result of Do, val is the value set by the command
+type Cmd struct {
+	result
+	val interface{}
+}
+
+// NewCmd copies args, Name lower cases the name in the args of the result
+// and the caller's slice is never modified
+func NewCmd(args ...interface{}) *Cmd {
+	return &Cmd{
+		result: result{_args: append([]interface{}(nil), args...)},
+	}
+}
+
+func (r *Cmd) SetVal(val interface{}) {
+	r.val = val
+}
+
+func (r *Cmd) Val() interface{} {
+	return r.val
+}
+
+func (r *Cmd) Result() (interface{}, error) {
+	return r.val, r.err
+}
+
+// Text returns the value as a string, integers are formatted in decimal
+func (r *Cmd) Text() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	switch val := r.val.(type) {
+	case []byte:
+		return string(val), nil
+	case string:
+		return val, nil
+	case int:
+		return strconv.Itoa(val), nil
+	default:
+		return "", fmt.Errorf("mem-cache: unexpected type=%T for Text", val)
+	}
+}
+
+// Int returns the value as an int, strings are parsed in decimal
+func (r *Cmd) Int() (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	switch val := r.val.(type) {
+	case int:
+		return val, nil
+	case []byte:
+		return strconv.Atoi(string(val))
+	case string:
+		return strconv.Atoi(val)
+	default:
+		return 0, fmt.Errorf("mem-cache: unexpected type=%T for Int", val)
+	}
+}
+
+// Bool returns the value as a bool, integers are true when not 0 like go-redis
+func (r *Cmd) Bool() (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	switch val := r.val.(type) {
+	case bool:
+		return val, nil
+	case int:
+		return val != 0, nil
+	case []byte:
+		return strconv.ParseBool(string(val))
+	case string:
+		return strconv.ParseBool(val)
+	default:
+		return false, fmt.Errorf("mem-cache: unexpected type=%T for Bool", val)
+	}
+}
+
+// Slice returns the value as a slice, for commands returning multiple values
+func (r *Cmd) Slice() ([]interface{}, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	switch val := r.val.(type) {
+	case []interface{}:
+		return val, nil
+	case []string:
+		s := make([]interface{}, len(val))
+		for i, v := range val {
+			s[i] = v
+		}
+		return s, nil
+	case []*Item:
+		s := make([]interface{}, len(val))
+		for i, v := range val {
+			s[i] = v
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("mem-cache: unexpected type=%T for Slice", val)
+	}
+}
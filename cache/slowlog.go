@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultSlowLogSlowerThan is used when CacheConf.SlowLogSlowerThan is not set, 10ms
+	DefaultSlowLogSlowerThan = 10000
+	// DefaultSlowLogMaxLen is used when CacheConf.SlowLogMaxLen is not set
+	DefaultSlowLogMaxLen = 128
+	// arguments are truncated like redis
+	slowLogMaxArgc   = 32
+	slowLogMaxArgLen = 128
+)
+
+// SlowLogEntry is a command slower than CacheConf.SlowLogSlowerThan,
+// Duration is the time running the command with the data lock held
+type SlowLogEntry struct {
+	ID       int64
+	Time     time.Time
+	Duration time.Duration
+	DB       int
+	// Args including the command name, at most 32 arguments of at most
+	// 128 bytes are kept
+	Args   []string
+	Client ClientInfo
+}
+
+// slowLog is a ring buffer guarded by the data lock
+type slowLog struct {
+	slowerThan time.Duration
+	entries    []SlowLogEntry
+	// next is the position of the next entry when entries is full
+	next   int
+	nextID int64
+}
+
+func newSlowLog(conf *CacheConf) *slowLog {
+	slowerThan := conf.SlowLogSlowerThan
+	if slowerThan == 0 {
+		slowerThan = DefaultSlowLogSlowerThan
+	}
+	maxLen := conf.SlowLogMaxLen
+	if maxLen <= 0 {
+		maxLen = DefaultSlowLogMaxLen
+	}
+	return &slowLog{
+		slowerThan: time.Duration(slowerThan) * time.Microsecond,
+		entries:    make([]SlowLogEntry, 0, maxLen),
+	}
+}
+
+// add logs the command of r when d reaches the threshold, with the client of ctx
+func (sl *slowLog) add(ctx context.Context, db int, r IResult, d time.Duration) {
+	if sl.slowerThan < 0 || d < sl.slowerThan {
+		return
+	}
+	client, _ := ClientInfoFromContext(ctx)
+	entry := SlowLogEntry{
+		ID:       sl.nextID,
+		Time:     time.Now(),
+		Duration: d,
+		DB:       db,
+		Args:     slowLogArgs(r),
+		Client:   client,
+	}
+	sl.nextID++
+	if len(sl.entries) < cap(sl.entries) {
+		sl.entries = append(sl.entries, entry)
+		return
+	}
+	sl.entries[sl.next] = entry
+	sl.next = (sl.next + 1) % len(sl.entries)
+}
+
+// get returns at most n entries, the newest first, all entries if n is negative
+func (sl *slowLog) get(n int) []SlowLogEntry {
+	if n < 0 || n > len(sl.entries) {
+		n = len(sl.entries)
+	}
+	entries := make([]SlowLogEntry, 0, n)
+	// the newest entry is before next
+	for i := 1; i <= n; i++ {
+		pos := (sl.next - i + len(sl.entries)) % len(sl.entries)
+		entries = append(entries, sl.entries[pos])
+	}
+	return entries
+}
+
+func (sl *slowLog) reset() {
+	sl.entries = sl.entries[:0]
+	sl.next = 0
+}
+
+// slowLogArgs formats the arguments of r, truncated like redis
+func slowLogArgs(r IResult) []string {
+	all := append([]interface{}{r.Name()}, r.Args()...)
+	argc := len(all)
+	if argc > slowLogMaxArgc {
+		argc = slowLogMaxArgc - 1
+	}
+	args := make([]string, 0, argc+1)
+	for _, arg := range all[:argc] {
+		s := formatArg(arg)
+		if len(s) > slowLogMaxArgLen {
+			s = fmt.Sprintf("%s... (%d more bytes)", s[:slowLogMaxArgLen], len(s)-slowLogMaxArgLen)
+		}
+		args = append(args, s)
+	}
+	if argc < len(all) {
+		args = append(args, fmt.Sprintf("... (%d more arguments)", len(all)-argc))
+	}
+	return args
+}
+
+// formatArg returns the argument of a command as string
+func formatArg(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	case []IResult:
+		return formatExec(arg)
+	}
+	return fmt.Sprint(arg)
+}
+
+// SlowLogGet returns at most n entries of the slow log, the newest first,
+// all entries if n is negative
+func (s *MemCache) SlowLogGet(n int) *SlowLogResult {
+	cmd := NewSlowLogResult("slowlog", "get", n)
+	s.l.Lock()
+	defer s.l.Unlock()
+	cmd.SetVal(s.slowLog.get(n))
+	return cmd
+}
+
+// SlowLogLen returns the count of entries of the slow log
+func (s *MemCache) SlowLogLen() *IntResult {
+	cmd := NewIntResult("slowlog", "len")
+	s.l.Lock()
+	defer s.l.Unlock()
+	cmd.SetVal(len(s.slowLog.entries))
+	return cmd
+}
+
+// SlowLogReset clears the slow log
+func (s *MemCache) SlowLogReset() *BoolResult {
+	cmd := NewBoolResult("slowlog", "reset")
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.slowLog.reset()
+	cmd.SetVal(true)
+	return cmd
+}
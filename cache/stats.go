@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats is a snapshot of the cache statistics
+type Stats struct {
+	Uptime time.Duration
+	// commands run by doWithTransaction
+	CommandsProcessed int64
+	// results of the readonly commands, a miss is a Nil result
+	KeyspaceHits   int64
+	KeyspaceMisses int64
+	// keys deleted by the eviction policy
+	EvictedKeys int64
+	// keys deleted because of ttl, by both the lazy and the active expiry,
+	// ActiveExpiredKeys are the ones deleted by the ttl goroutine
+	ExpiredKeys       int64
+	ActiveExpiredKeys int64
+	// commands rejected by MaxSize or MaxMemoryBytes
+	RejectedByLimit int64
+	// estimated memory of all databases, and the limit of every database
+	UsedMemory      int64
+	MaxMemory       int64
+	MaxMemoryPolicy EvictionPolicy
+	// cycles of the ttl goroutine and the time spent in them
+	ExpireCycles        int64
+	ExpireCycleTime     time.Duration
+	LastExpireCycleTime time.Duration
+	// statistics of every command by name
+	Commands map[string]CommandStats
+	// errors except Nil by type like ErrorTypeWrongType, including the
+	// unknown commands and the commands after Close
+	Errors map[string]int64
+	// databases having keys
+	Keyspace []KeyspaceStats
+}
+
+// CommandStats is the statistics of a command
+type CommandStats struct {
+	Calls int64
+	// only counted for the readonly commands
+	Hits   int64
+	Misses int64
+	// errors except Nil, including RejectedByLimit
+	Errors          int64
+	RejectedByLimit int64
+	// LockWait is the time waiting for the data lock, Exec is the time
+	// running the command with the lock held
+	LockWait Histogram
+	Exec     Histogram
+}
+
+// KeyspaceStats is the statistics of a database
+type KeyspaceStats struct {
+	DB int
+	// Keys count, Expires is the count of keys with ttl
+	Keys    int
+	Expires int
+	Strings int
+	Hashes  int
+	Sets    int
+	// AvgTTL is the average remaining ttl of the keys with ttl
+	AvgTTL time.Duration
+}
+
+// coreStats is updated with the data lock held
+type coreStats struct {
+	started             time.Time
+	commands            map[string]*CommandStats
+	errors              map[string]int64
+	expireCycles        int64
+	expireCycleTime     time.Duration
+	lastExpireCycleTime time.Duration
+}
+
+// record counts the result and the latency of cmd
+func (st *coreStats) record(cmd *command, r IResult, wait, exec time.Duration) {
+	cs := st.commands[cmd.name]
+	if cs == nil {
+		cs = &CommandStats{}
+		st.commands[cmd.name] = cs
+	}
+	cs.Calls++
+	cs.LockWait.observe(wait)
+	cs.Exec.observe(exec)
+	err := r.Err()
+	if err != nil && err != Nil {
+		st.recordError(err)
+	}
+	switch {
+	case err == Nil:
+		if cmd.spec.Flags&FlagReadOnly != 0 {
+			cs.Misses++
+		}
+	case err != nil:
+		cs.Errors++
+		if errors.Is(err, ErrLimitExceeded) {
+			cs.RejectedByLimit++
+		}
+	default:
+		if cmd.spec.Flags&FlagReadOnly != 0 {
+			cs.Hits++
+		}
+	}
+}
+
+// recordError counts err by type
+func (st *coreStats) recordError(err error) {
+	st.errors[errorType(err)]++
+}
+
+// recordExpireCycle counts a cycle of the ttl goroutine
+func (st *coreStats) recordExpireCycle(d time.Duration) {
+	st.expireCycles++
+	st.expireCycleTime += d
+	st.lastExpireCycleTime = d
+}
+
+func (db *MemCacheDB) keyspaceStats(now time.Time) KeyspaceStats {
+	ks := KeyspaceStats{
+		DB:      db.id,
+		Keys:    db.count,
+		Expires: len(db.ttl),
+		Strings: len(db.s),
+		Hashes:  len(db.hm),
+		Sets:    len(db.hs),
+	}
+	if len(db.ttl) > 0 {
+		var total time.Duration
+		for _, expireAt := range db.ttl {
+			if ttl := expireAt.Sub(now); ttl > 0 {
+				total += ttl
+			}
+		}
+		ks.AvgTTL = total / time.Duration(len(db.ttl))
+	}
+	return ks
+}
+
+// info sections in the order of INFO
+var infoSections = []string{"server", "memory", "persistence", "stats", "commandstats", "keyspace"}
+
+// formatInfo writes the sections of stats like redis INFO, all sections when
+// sections is empty or "all", "everything" or "default"
+func formatInfo(stats *Stats, sections []string) string {
+	want := map[string]bool{}
+	for _, section := range sections {
+		section = strings.ToLower(section)
+		switch section {
+		case "all", "everything":
+			for _, name := range infoSections {
+				want[name] = true
+			}
+		case "default":
+			for _, name := range infoSections {
+				want[name] = name != "commandstats"
+			}
+		default:
+			want[section] = true
+		}
+	}
+	if len(sections) == 0 {
+		for _, name := range infoSections {
+			want[name] = name != "commandstats"
+		}
+	}
+	var b strings.Builder
+	section := func(name string) bool {
+		if !want[name] {
+			return false
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s%s\r\n", strings.ToUpper(name[:1]), name[1:])
+		return true
+	}
+	field := func(name string, val interface{}) {
+		fmt.Fprintf(&b, "%s:%v\r\n", name, val)
+	}
+	if section("server") {
+		field("uptime_in_seconds", int64(stats.Uptime/time.Second))
+		field("uptime_in_days", int64(stats.Uptime/(24*time.Hour)))
+	}
+	if section("memory") {
+		field("used_memory", stats.UsedMemory)
+		field("maxmemory", stats.MaxMemory)
+		policy := stats.MaxMemoryPolicy
+		if policy == "" {
+			policy = NoEviction
+		}
+		field("maxmemory_policy", policy)
+	}
+	if section("persistence") {
+		// nothing is written to disk yet
+		field("loading", 0)
+		field("persistence_enabled", 0)
+	}
+	if section("stats") {
+		field("total_commands_processed", stats.CommandsProcessed)
+		field("keyspace_hits", stats.KeyspaceHits)
+		field("keyspace_misses", stats.KeyspaceMisses)
+		field("expired_keys", stats.ExpiredKeys)
+		field("expired_keys_active", stats.ActiveExpiredKeys)
+		field("evicted_keys", stats.EvictedKeys)
+		field("rejected_by_limit", stats.RejectedByLimit)
+		field("expire_cycles", stats.ExpireCycles)
+		field("expire_cycle_time_us", int64(stats.ExpireCycleTime/time.Microsecond))
+		field("expire_cycle_last_time_us", int64(stats.LastExpireCycleTime/time.Microsecond))
+	}
+	if section("commandstats") {
+		names := make([]string, 0, len(stats.Commands))
+		for name := range stats.Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cs := stats.Commands[name]
+			field("cmdstat_"+name, fmt.Sprintf("calls=%d,hits=%d,misses=%d,errors=%d,rejected_by_limit=%d",
+				cs.Calls, cs.Hits, cs.Misses, cs.Errors, cs.RejectedByLimit))
+		}
+	}
+	if section("keyspace") {
+		for _, ks := range stats.Keyspace {
+			field(fmt.Sprintf("db%d", ks.DB), fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d,strings=%d,hashes=%d,sets=%d",
+				ks.Keys, ks.Expires, int64(ks.AvgTTL/time.Millisecond), ks.Strings, ks.Hashes, ks.Sets))
+		}
+	}
+	return b.String()
+}
@@ -9,65 +9,105 @@ func initHmap() hmap {
 }
 
 // register cmd when add a operate
-func commandHashMap(db *MemCacheDB) {
-	db.register("hset", db.hset)
-	db.register("hget", db.hget)
-	db.register("hdel", db.hdel)
+func commandHashMap() {
+	register("hset", CommandSpec{
+		Arity:    4,
+		Flags:    FlagWrite | FlagDenyOOM | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "hash",
+		Summary:  "Set the value of a hash field",
+	}, (*MemCacheDB).hset, argString, argString, argBytes)
+	register("hget", CommandSpec{
+		Arity:    3,
+		Flags:    FlagReadOnly | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "hash",
+		Summary:  "Get the value of a hash field",
+	}, (*MemCacheDB).hget, argString, argString)
+	register("hdel", CommandSpec{
+		Arity:    -3,
+		Flags:    FlagWrite | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "hash",
+		Summary:  "Delete hash fields",
+	}, (*MemCacheDB).hdel, argString, argStrings)
 }
 
 // field exist return 0， new field return 1
 func (db *MemCacheDB) hset(result IResult) {
 	if len(result.Args()) != 3 {
-		result.SetError(fmt.Errorf("hset need 3 argument"))
+		result.SetError(fmt.Errorf("%w: hset need 3 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("hset argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: hset argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("hset argument 2 shuold be string"))
+		result.SetError(fmt.Errorf("%w: hset argument 2 should be string", ErrInvalidArgument))
 		return
 	}
 	arg2, ok := result.Args()[2].([]byte)
 	if !ok {
-		result.SetError(fmt.Errorf("hset argument 3 shuold be []byte"))
+		result.SetError(fmt.Errorf("%w: hset argument 3 should be []byte", ErrInvalidArgument))
 		return
 	}
+	// nil value is stored as empty, nil means not exist
+	if arg2 == nil {
+		arg2 = []byte{}
+	}
 	err := db.doBeforeProcess(arg0, HASH)
 	if err != nil {
 		result.SetError(err)
 		return
 	}
+	err = db.checkMemory()
+	if err != nil {
+		result.SetError(err)
+		return
+	}
 	res := 0
 	if db.hm[arg0] == nil {
-		db.addKey(arg0, HASH)
+		_, err = db.addKey(arg0, HASH)
+		if err != nil {
+			result.SetError(err)
+			return
+		}
 		db.hm[arg0] = make(map[string][]byte)
 	}
-	if db.hm[arg0][arg1] == nil {
+	if old := db.hm[arg0][arg1]; old == nil {
 		res = 1
+		db.incrMem(arg0, fieldSize(arg1, arg2))
+	} else {
+		db.incrMem(arg0, int64(len(arg2)-len(old)))
 	}
 	db.hm[arg0][arg1] = arg2
-
+	db.notify(notifyHash, "hset", arg0)
 	result.SetVal(res)
 }
 
 // return string value
 func (db *MemCacheDB) hget(result IResult) {
 	if len(result.Args()) != 2 {
-		result.SetError(fmt.Errorf("hget need 2 argument"))
+		result.SetError(fmt.Errorf("%w: hget need 2 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("hgel argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: hget argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("hget argument 2 shuold be string"))
+		result.SetError(fmt.Errorf("%w: hget argument 2 should be string", ErrInvalidArgument))
 		return
 	}
 	err := db.doBeforeProcess(arg0, HASH)
@@ -75,27 +115,28 @@ func (db *MemCacheDB) hget(result IResult) {
 		result.SetError(err)
 		return
 	}
-	if db.hm[arg0] == nil {
-		result.SetVal([]byte(""))
+	val, ok := db.hm[arg0][arg1]
+	if !ok {
+		result.SetError(Nil)
 		return
 	}
-	result.SetVal(db.hm[arg0][arg1])
+	result.SetVal(val)
 }
 
 // field can be multi, return field count that del successful
 func (db *MemCacheDB) hdel(result IResult) {
 	if len(result.Args()) < 2 {
-		result.SetError(fmt.Errorf("hdel need at least 2 argument"))
+		result.SetError(fmt.Errorf("%w: hdel need at least 2 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("hdel argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: hdel argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].([]string)
 	if !ok {
-		result.SetError(fmt.Errorf("hdel argument 2 shuold be []string"))
+		result.SetError(fmt.Errorf("%w: hdel argument 2 should be []string", ErrInvalidArgument))
 		return
 	}
 	err := db.doBeforeProcess(arg0, HASH)
@@ -113,8 +154,12 @@ func (db *MemCacheDB) hdel(result IResult) {
 	for _, fieldTemp := range keys {
 		if db.hm[key][fieldTemp] != nil {
 			res++
+			db.incrMem(key, -fieldSize(fieldTemp, db.hm[key][fieldTemp]))
 			delete(db.hm[key], fieldTemp)
 		}
 	}
+	if res > 0 {
+		db.notify(notifyHash, "hdel", key)
+	}
 	result.SetVal(res)
 }
@@ -9,25 +9,41 @@ func initHset() hset {
 }
 
 // register cmd when add a operate
-func commandHashSet(db *MemCacheDB) {
-	db.register("sadd", db.sAdd)
-	db.register("sismember", db.sIsMember)
+func commandHashSet() {
+	register("sadd", CommandSpec{
+		Arity:    -3,
+		Flags:    FlagWrite | FlagDenyOOM | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "set",
+		Summary:  "Add members to a set",
+	}, (*MemCacheDB).sAdd, argString, argStrings)
+	register("sismember", CommandSpec{
+		Arity:    3,
+		Flags:    FlagReadOnly | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "set",
+		Summary:  "Determine if a member is in a set",
+	}, (*MemCacheDB).sIsMember, argString, argString)
 }
 
 // member exist return 0， new member return new member count
 func (db *MemCacheDB) sAdd(result IResult) {
 	if len(result.Args()) < 2 {
-		result.SetError(fmt.Errorf("sadd need at least 2 argument"))
+		result.SetError(fmt.Errorf("%w: sadd need at least 2 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("sadd argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: sadd argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].([]string)
 	if !ok {
-		result.SetError(fmt.Errorf("sadd argument 2 shuold be []string"))
+		result.SetError(fmt.Errorf("%w: sadd argument 2 should be []string", ErrInvalidArgument))
 		return
 	}
 	err := db.doBeforeProcess(arg0, Set)
@@ -35,9 +51,18 @@ func (db *MemCacheDB) sAdd(result IResult) {
 		result.SetError(err)
 		return
 	}
+	err = db.checkMemory()
+	if err != nil {
+		result.SetError(err)
+		return
+	}
 	res := 0
 	if db.hs[arg0] == nil {
-		db.addKey(arg0, Set)
+		_, err = db.addKey(arg0, Set)
+		if err != nil {
+			result.SetError(err)
+			return
+		}
 		db.hs[arg0] = make(map[string]float64)
 	}
 	keys := arg1
@@ -46,25 +71,29 @@ func (db *MemCacheDB) sAdd(result IResult) {
 		if db.hs[arg0][member] == 0 {
 			res++
 			db.hs[arg0][member] = 1
+			db.incrMem(arg0, memberSize(member))
 		}
 	}
+	if res > 0 {
+		db.notify(notifySet, "sadd", arg0)
+	}
 	result.SetVal(res)
 }
 
 // member exist return 0， new member return new member count
 func (db *MemCacheDB) sIsMember(result IResult) {
 	if len(result.Args()) != 2 {
-		result.SetError(fmt.Errorf("sismember need 2 argument"))
+		result.SetError(fmt.Errorf("%w: sismember need 2 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("sismember argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: sismember argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("sismember argument 2 shuold be string"))
+		result.SetError(fmt.Errorf("%w: sismember argument 2 should be string", ErrInvalidArgument))
 		return
 	}
 	err := db.doBeforeProcess(arg0, Set)
@@ -12,22 +12,54 @@ func initStr() str {
 }
 
 // register cmd when add a operate
-func commandString(db *MemCacheDB) {
-	db.register("set", db.set)
-	db.register("get", db.get)
-	db.register("del", db.del)
-	db.register("expire", db.expire)
+func commandString() {
+	register("set", CommandSpec{
+		Arity:    3,
+		Flags:    FlagWrite | FlagDenyOOM,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "string",
+		Summary:  "Set the string value of a key",
+	}, (*MemCacheDB).set, argString, argBytes)
+	register("get", CommandSpec{
+		Arity:    2,
+		Flags:    FlagReadOnly | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "string",
+		Summary:  "Get the value of a key",
+	}, (*MemCacheDB).get, argString)
+	register("del", CommandSpec{
+		Arity:    -2,
+		Flags:    FlagWrite,
+		FirstKey: 1,
+		LastKey:  -1,
+		KeyStep:  1,
+		Group:    "generic",
+		Summary:  "Delete keys of any type",
+	}, (*MemCacheDB).del, argStrings)
+	register("expire", CommandSpec{
+		Arity:    3,
+		Flags:    FlagWrite | FlagFast,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		Group:    "generic",
+		Summary:  "Set a key's time to live in seconds",
+	}, (*MemCacheDB).expire, argString, argInt)
 }
 
 // return a string
 func (db *MemCacheDB) get(result IResult) {
 	if len(result.Args()) != 1 {
-		result.SetError(fmt.Errorf("get need 1 argument"))
+		result.SetError(fmt.Errorf("%w: get need 1 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("get argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: get argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	err := db.doBeforeProcess(arg0, STRING)
@@ -35,49 +67,66 @@ func (db *MemCacheDB) get(result IResult) {
 		result.SetError(err)
 		return
 	}
-	val := db.s[arg0]
+	val, ok := db.s[arg0]
+	if !ok {
+		result.SetError(Nil)
+		return
+	}
 	result.SetVal(val)
 }
 
 // return true
 func (db *MemCacheDB) set(result IResult) {
 	if len(result.Args()) != 2 {
-		result.SetError(fmt.Errorf("set need 2 argument"))
+		result.SetError(fmt.Errorf("%w: set need 2 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("set argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: set argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].([]byte)
 	if !ok {
-		result.SetError(fmt.Errorf("set argument 2 shuold be []byte"))
+		result.SetError(fmt.Errorf("%w: set argument 2 should be []byte", ErrInvalidArgument))
 		return
 	}
+	// nil value is stored as empty, nil means not exist
+	if arg1 == nil {
+		arg1 = []byte{}
+	}
 	err := db.doBeforeProcess(arg0, STRING)
 	if err != nil {
 		result.SetError(err)
 		return
 	}
+	err = db.checkMemory()
+	if err != nil {
+		result.SetError(err)
+		return
+	}
 	_, err = db.addKey(arg0, STRING)
 	if err != nil {
 		result.SetError(err)
 		return
 	}
+	db.incrMem(arg0, int64(len(arg1)-len(db.s[arg0])))
 	db.s[arg0] = arg1
+	// memcached flags are reset, and a new cas is given on next gets
+	delete(db.smeta, arg0)
+	db.notify(notifyString, "set", arg0)
 	result.SetVal(true)
 }
 
 // keys can be multi, return count that keys be deleted
 func (db *MemCacheDB) del(result IResult) {
 	if len(result.Args()) < 1 {
-		result.SetError(fmt.Errorf("del need at least 1 argument"))
+		result.SetError(fmt.Errorf("%w: del need at least 1 argument", ErrWrongArgCount))
 		return
 	}
 	keys, ok := result.Args()[0].([]string)
 	if !ok {
-		result.SetError(fmt.Errorf("del keys must be []string"))
+		result.SetError(fmt.Errorf("%w: del keys should be []string", ErrInvalidArgument))
 		return
 	}
 	for _, key := range keys {
@@ -93,6 +142,7 @@ func (db *MemCacheDB) del(result IResult) {
 		d, _ := db.delKey(elem, true)
 		if d {
 			res++
+			db.notify(notifyGeneric, "del", elem)
 		}
 	}
 	result.SetVal(res)
@@ -100,26 +150,31 @@ func (db *MemCacheDB) del(result IResult) {
 
 func (db *MemCacheDB) expire(result IResult) {
 	if len(result.Args()) != 2 {
-		result.SetError(fmt.Errorf("expire need 2 argument"))
+		result.SetError(fmt.Errorf("%w: expire need 2 argument", ErrWrongArgCount))
 		return
 	}
 	arg0, ok := result.Args()[0].(string)
 	if !ok {
-		result.SetError(fmt.Errorf("expire argument 1 shuold be string"))
+		result.SetError(fmt.Errorf("%w: expire argument 1 should be string", ErrInvalidArgument))
 		return
 	}
 	arg1, ok := result.Args()[1].(int)
 	if !ok {
-		result.SetError(fmt.Errorf("expire argument 2 shuold be integer in [1, 2147483647]"))
+		result.SetError(fmt.Errorf("%w: expire argument 2 should be integer in [1, 2147483647]", ErrInvalidArgument))
 		return
 	}
 	if arg1 <= 0 {
-		result.SetError(fmt.Errorf("expire seconds can't <= 0, should be integer in [1, 2147483647]"))
+		result.SetError(fmt.Errorf("%w: expire seconds can't <= 0, should be integer in [1, 2147483647]", ErrInvalidArgument))
+		return
 	}
 	if db.keys[arg0] == DEFAULT { //key don't exist
 		result.SetVal(0)
 		return
 	}
+	if db.ttl[arg0].IsZero() {
+		db.incrMem(arg0, ttlOverhead)
+	}
 	db.ttl[arg0] = time.Now().Add(time.Duration(arg1) * time.Second)
+	db.notify(notifyGeneric, "expire", arg0)
 	result.SetVal(1)
 }
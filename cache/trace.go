@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// attribute keys of the spans, named like the database conventions of OpenTelemetry
+const (
+	AttrDBSystem     = "db.system"
+	AttrDBOperation  = "db.operation"
+	AttrDBKey        = "db.mem_cache.key"
+	AttrDBResultSize = "db.mem_cache.result_size"
+)
+
+// Tracer starts a span for every command, it is a small subset of an
+// OpenTelemetry tracer so an adapter is a few lines
+type Tracer interface {
+	// Start starts a span named after the command, the returned context
+	// carries the span to the hooks and the command
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a command being traced
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError is called when the command fails, a Nil result is not an error
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key value pair of a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// NoopTracer starts spans doing nothing, a MemCache without CacheConf.Tracer
+// traces nothing at all
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End()                             {}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span of ctx, nil if ctx has none
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// tracingHook starts a span around every command, it is the first hook so
+// the span covers the other hooks
+type tracingHook struct {
+	tracer   Tracer
+	hashKeys bool
+	// looks up the key positions of the commands
+	core *core
+}
+
+func (h *tracingHook) BeforeProcess(ctx context.Context, r IResult) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, r.Name())
+	attrs := []Attribute{
+		{AttrDBSystem, "mem-cache"},
+		{AttrDBOperation, r.Name()},
+	}
+	if key, ok := firstKey(h.core.lookupCommand(r.Name()), r); ok {
+		if h.hashKeys {
+			sum := sha256.Sum256([]byte(key))
+			key = hex.EncodeToString(sum[:16])
+		}
+		attrs = append(attrs, Attribute{AttrDBKey, key})
+	}
+	span.SetAttributes(attrs...)
+	return ContextWithSpan(ctx, span), nil
+}
+
+func (h *tracingHook) AfterProcess(ctx context.Context, r IResult, d time.Duration) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if size, ok := resultSize(r); ok {
+		span.SetAttributes(Attribute{AttrDBResultSize, size})
+	}
+	if err := r.Err(); err != nil && err != Nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// firstKey returns the first key of r by the key positions of the spec of cmd
+func firstKey(cmd *command, r IResult) (string, bool) {
+	args := r.Args()
+	if cmd == nil || cmd.spec.FirstKey <= 0 || cmd.spec.FirstKey > len(args) {
+		return "", false
+	}
+	switch arg := args[cmd.spec.FirstKey-1].(type) {
+	case string:
+		return arg, true
+	case []byte:
+		return string(arg), true
+	case []string:
+		// keys of the typed api like Del
+		if len(arg) > 0 {
+			return arg[0], true
+		}
+	}
+	return "", false
+}
+
+// resultSize returns the bytes of a value or the count of a list
+func resultSize(r IResult) (int, bool) {
+	if r.Err() != nil {
+		return 0, false
+	}
+	var val interface{}
+	switch r := r.(type) {
+	case *BytesResult:
+		val = r.val
+	case *StringResult:
+		val = r.val
+	case *StringsResult:
+		val = r.val
+	case *ItemsResult:
+		val = r.val
+	case *Cmd:
+		val = r.val
+	}
+	switch val := val.(type) {
+	case []byte:
+		return len(val), true
+	case string:
+		return len(val), true
+	case []string:
+		return len(val), true
+	case []*Item:
+		return len(val), true
+	case []interface{}:
+		return len(val), true
+	}
+	return 0, false
+}
+
+// InMemoryTracer keeps the ended spans in memory, for tests
+type InMemoryTracer struct {
+	mu    sync.Mutex
+	spans []*InMemorySpan
+}
+
+// InMemorySpan is a span of InMemoryTracer, Parent is the span of the
+// context passed to Start
+type InMemorySpan struct {
+	tracer     *InMemoryTracer
+	Name       string
+	Parent     Span
+	Attributes map[string]interface{}
+	Err        error
+	StartTime  time.Time
+	EndTime    time.Time
+}
+
+func (t *InMemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &InMemorySpan{
+		tracer:     t,
+		Name:       name,
+		Parent:     SpanFromContext(ctx),
+		Attributes: make(map[string]interface{}),
+		StartTime:  time.Now(),
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the ended spans in the order they ended
+func (t *InMemoryTracer) Spans() []*InMemorySpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*InMemorySpan(nil), t.spans...)
+}
+
+// Reset forgets the ended spans
+func (t *InMemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+func (s *InMemorySpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *InMemorySpan) RecordError(err error) {
+	s.Err = err
+}
+
+func (s *InMemorySpan) End() {
+	s.EndTime = time.Now()
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
@@ -21,6 +21,9 @@ func volatileRange(db *MemCacheDB) {
 		delRate = float64(len(delKey)) / float64(allCount)
 		for _, key := range delKey {
 			db.delKey(key, true)
+			db.expired++
+			db.activeExpired++
+			db.notify(notifyExpired, "expired", key)
 		}
 	}
 }
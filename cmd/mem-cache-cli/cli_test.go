@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wangyanga9/mem-cache/resp"
+)
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`  set "key 1" 'it\'s' "a\x41\n"  `)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(args, []string{"set", "key 1", "it's", "aA\n"}) {
+		t.Fatal("split result error, args=", args)
+	}
+	_, err = splitArgs(`get "key`)
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+}
+
+func TestSensitiveCommand(t *testing.T) {
+	cases := []struct {
+		line      string
+		sensitive bool
+	}{
+		{"AUTH secret", true},
+		{"auth alice secret", true},
+		{"acl SETUSER alice on >secret", true},
+		{"hello 3 AUTH alice secret", true},
+		{"hello 3", false},
+		{"acl whoami", false},
+		{"get auth", false},
+	}
+	for _, c := range cases {
+		args, _ := splitArgs(c.line)
+		if sensitiveCommand(args) != c.sensitive {
+			t.Fatal("sensitive command error, line=", c.line)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	bulk := func(s string) resp.Value {
+		return resp.Value{Type: resp.BulkString, Str: []byte(s)}
+	}
+	v := resp.Value{Type: resp.Array, Elems: []resp.Value{
+		bulk("a"),
+		{Type: resp.Array, Elems: []resp.Value{{Type: resp.Integer, Int: 1}, {Type: resp.BulkString, IsNull: true}}},
+		{Type: resp.Map, Elems: []resp.Value{bulk("k"), bulk("v")}},
+	}}
+	want := "1) \"a\"\n" +
+		"2) 1) (integer) 1\n" +
+		"   2) (nil)\n" +
+		"3) 1# \"k\" => \"v\"\n"
+	if got := formatValue(v, false); got != want {
+		t.Fatalf("format result error, got:\n%s", got)
+	}
+	if got := formatValue(v, true); got != "a\n1\n\nk\nv\n" {
+		t.Fatalf("raw format result error, got:\n%q", got)
+	}
+	if got := formatValue(resp.Value{Type: resp.Error, Str: []byte("ERR x")}, false); got != "(error) ERR x\n" {
+		t.Fatal("format error result error")
+	}
+}
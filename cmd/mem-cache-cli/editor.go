@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var errInterrupt = errors.New("interrupt")
+
+// editor reads lines from a terminal in raw mode, supporting history with
+// the up and down keys and completion of the command name with tab. When
+// input is not a terminal lines are read as they are.
+type editor struct {
+	in       *os.File
+	out      io.Writer
+	rd       *bufio.Reader
+	history  []string
+	complete func(prefix string) []string
+}
+
+func newEditor(in *os.File, out io.Writer) *editor {
+	return &editor{in: in, out: out, rd: bufio.NewReader(in)}
+}
+
+func (ed *editor) addHistory(line string) {
+	if n := len(ed.history); n > 0 && ed.history[n-1] == line {
+		return
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > maxHistory {
+		ed.history = ed.history[1:]
+	}
+}
+
+func (ed *editor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(ed.in)
+	if err != nil {
+		// not a terminal, no prompt like redis-cli
+		line, err := ed.rd.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+
+	var buf []rune
+	pos := 0
+	// index in history, len(history) is the line being edited
+	hpos := len(ed.history)
+	editing := ""
+	refresh := func() {
+		fmt.Fprintf(ed.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(ed.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(line string) {
+		buf = []rune(line)
+		pos = len(buf)
+		refresh()
+	}
+	fmt.Fprint(ed.out, prompt)
+	for {
+		r, _, err := ed.rd.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return string(buf), nil
+		case 3: // ctrl-c
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // ctrl-d
+			if len(buf) == 0 {
+				fmt.Fprint(ed.out, "\r\n")
+				return "", io.EOF
+			}
+		case 1: // ctrl-a
+			pos = 0
+			refresh()
+		case 5: // ctrl-e
+			pos = len(buf)
+			refresh()
+		case 21: // ctrl-u
+			buf = buf[:0]
+			pos = 0
+			refresh()
+		case 127, 8: // backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+				refresh()
+			}
+		case '\t':
+			ed.completeLine(&buf, &pos, prompt)
+			refresh()
+		case 27: // escape sequence
+			seq := make([]byte, 2)
+			if _, err := io.ReadFull(ed.rd, seq); err != nil {
+				return "", err
+			}
+			if seq[0] != '[' {
+				continue
+			}
+			switch seq[1] {
+			case 'A': // up
+				if hpos > 0 {
+					if hpos == len(ed.history) {
+						editing = string(buf)
+					}
+					hpos--
+					setLine(ed.history[hpos])
+				}
+			case 'B': // down
+				if hpos < len(ed.history) {
+					hpos++
+					if hpos == len(ed.history) {
+						setLine(editing)
+					} else {
+						setLine(ed.history[hpos])
+					}
+				}
+			case 'C': // right
+				if pos < len(buf) {
+					pos++
+					refresh()
+				}
+			case 'D': // left
+				if pos > 0 {
+					pos--
+					refresh()
+				}
+			}
+		default:
+			if r < 32 || r == utf8.RuneError {
+				continue
+			}
+			buf = append(buf, 0)
+			copy(buf[pos+1:], buf[pos:])
+			buf[pos] = r
+			pos++
+			refresh()
+		}
+	}
+}
+
+// completeLine completes the command name when the cursor is in the first word,
+// to the common prefix of the candidates, which are listed when more than one
+func (ed *editor) completeLine(buf *[]rune, pos *int, prompt string) {
+	line := string((*buf)[:*pos])
+	if ed.complete == nil || strings.ContainsAny(strings.TrimLeft(line, " "), " \t") {
+		return
+	}
+	prefix := strings.TrimLeft(line, " ")
+	candidates := ed.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(candidates) == 1 {
+		common += " "
+	} else if len(common) <= len(prefix) {
+		fmt.Fprintf(ed.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+	if len(common) < len(prefix) {
+		return
+	}
+	rest := []rune(string((*buf)[*pos:]))
+	*buf = append([]rune(common), rest...)
+	*pos = len([]rune(common))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wangyanga9/mem-cache/resp"
+)
+
+// formatValue formats a reply like redis-cli, one line per element of
+// nested arrays, sets and maps; raw mode prints strings as they are
+func formatValue(v resp.Value, raw bool) string {
+	var sb strings.Builder
+	if raw {
+		writeRaw(&sb, v)
+	} else {
+		writeValue(&sb, v, "")
+	}
+	return sb.String()
+}
+
+func writeRaw(sb *strings.Builder, v resp.Value) {
+	switch v.Type {
+	case resp.Integer:
+		sb.WriteString(strconv.FormatInt(v.Int, 10))
+		sb.WriteString("\n")
+	case resp.Boolean:
+		if v.Bool {
+			sb.WriteString("1\n")
+		} else {
+			sb.WriteString("0\n")
+		}
+	case resp.Array, resp.Set, resp.Push, resp.Map:
+		for _, elem := range v.Elems {
+			writeRaw(sb, elem)
+		}
+	default:
+		if !v.IsNull {
+			sb.Write(v.Str)
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// indent is the prefix of the lines after the first one of a nested value
+func writeValue(sb *strings.Builder, v resp.Value, indent string) {
+	if v.IsNull {
+		sb.WriteString("(nil)\n")
+		return
+	}
+	switch v.Type {
+	case resp.SimpleString:
+		sb.Write(v.Str)
+		sb.WriteString("\n")
+	case resp.Error, resp.BulkError:
+		sb.WriteString("(error) ")
+		sb.Write(v.Str)
+		sb.WriteString("\n")
+	case resp.Integer:
+		fmt.Fprintf(sb, "(integer) %d\n", v.Int)
+	case resp.Double:
+		fmt.Fprintf(sb, "(double) %s\n", v.Str)
+	case resp.BigNumber:
+		fmt.Fprintf(sb, "(big number) %s\n", v.Str)
+	case resp.Boolean:
+		if v.Bool {
+			sb.WriteString("(true)\n")
+		} else {
+			sb.WriteString("(false)\n")
+		}
+	case resp.BulkString, resp.Verbatim:
+		sb.WriteString(strconv.Quote(string(v.Str)))
+		sb.WriteString("\n")
+	case resp.Map:
+		if len(v.Elems) == 0 {
+			sb.WriteString("(empty hash)\n")
+			return
+		}
+		n := len(v.Elems) / 2
+		width := len(strconv.Itoa(n))
+		for i := 0; i < n; i++ {
+			prefix := fmt.Sprintf("%*d# ", width, i+1)
+			if i > 0 {
+				sb.WriteString(indent)
+			}
+			sb.WriteString(prefix)
+			// value follows the key on the same line
+			var key strings.Builder
+			writeValue(&key, v.Elems[2*i], indent+strings.Repeat(" ", len(prefix)))
+			sb.WriteString(strings.TrimSuffix(key.String(), "\n"))
+			sb.WriteString(" => ")
+			writeValue(sb, v.Elems[2*i+1], indent+strings.Repeat(" ", len(prefix)+4))
+		}
+	default:
+		// array, set and push
+		if len(v.Elems) == 0 {
+			if v.Type == resp.Set {
+				sb.WriteString("(empty set)\n")
+			} else {
+				sb.WriteString("(empty array)\n")
+			}
+			return
+		}
+		width := len(strconv.Itoa(len(v.Elems)))
+		for i, elem := range v.Elems {
+			prefix := fmt.Sprintf("%*d) ", width, i+1)
+			if v.Type == resp.Set {
+				prefix = fmt.Sprintf("%*d~ ", width, i+1)
+			}
+			if i > 0 {
+				sb.WriteString(indent)
+			}
+			sb.WriteString(prefix)
+			writeValue(sb, elem, indent+strings.Repeat(" ", len(prefix)))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Command mem-cache-cli is a redis-cli like client of mem-cache-server.
+//
+//	mem-cache-cli [-h host] [-p port] [-n db] [-user user] [-a password]
+//		[-tls [-cacert file] [-cert file -key file]] [-raw] [command [arg ...]]
+//
+// Without a command it starts a REPL with history and command completion.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wangyanga9/mem-cache/resp"
+)
+
+type client struct {
+	addr string
+	db   int
+	// user and password of AUTH, not sent when password is empty
+	user     string
+	password string
+	// connect with tls when not nil
+	tlsConfig *tls.Config
+	nc        net.Conn
+	rd        *resp.Reader
+	wr        *resp.Writer
+}
+
+func (cli *client) connect() error {
+	var nc net.Conn
+	var err error
+	if cli.tlsConfig != nil {
+		nc, err = tls.Dial("tcp", cli.addr, cli.tlsConfig)
+	} else {
+		nc, err = net.Dial("tcp", cli.addr)
+	}
+	if err != nil {
+		return err
+	}
+	cli.nc = nc
+	cli.rd = resp.NewReader(nc)
+	cli.wr = resp.NewWriter(nc)
+	if cli.password != "" {
+		args := []string{"auth", cli.password}
+		if cli.user != "" {
+			args = []string{"auth", cli.user, cli.password}
+		}
+		v, err := cli.do(args...)
+		if err != nil {
+			return err
+		}
+		if v.Type == resp.Error {
+			return fmt.Errorf("%s", v.Str)
+		}
+	}
+	if cli.db != 0 {
+		v, err := cli.do("select", strconv.Itoa(cli.db))
+		if err != nil {
+			return err
+		}
+		if v.Type == resp.Error {
+			return fmt.Errorf("%s", v.Str)
+		}
+	}
+	return nil
+}
+
+// do sends a command and reads the reply, reconnect once when the connection is lost
+func (cli *client) do(args ...string) (resp.Value, error) {
+	if cli.nc == nil {
+		if err := cli.connect(); err != nil {
+			return resp.Value{}, err
+		}
+	}
+	cli.wr.WriteCommand(args...)
+	err := cli.wr.Flush()
+	if err != nil {
+		cli.close()
+		return resp.Value{}, err
+	}
+	v, err := cli.rd.ReadValue()
+	if err != nil {
+		cli.close()
+		return resp.Value{}, err
+	}
+	if strings.ToLower(args[0]) == "select" && len(args) == 2 && v.Type != resp.Error {
+		cli.db, _ = strconv.Atoi(args[1])
+	}
+	return v, nil
+}
+
+func (cli *client) close() {
+	if cli.nc != nil {
+		cli.nc.Close()
+		cli.nc = nil
+	}
+}
+
+// commandNames asks the server for its command table, used by completion
+func (cli *client) commandNames() []string {
+	v, err := cli.do("command")
+	if err != nil || v.Type == resp.Error {
+		return nil
+	}
+	names := make([]string, 0, len(v.Elems))
+	for _, entry := range v.Elems {
+		if len(entry.Elems) > 0 {
+			names = append(names, strings.ToUpper(entry.Elems[0].String()))
+		}
+	}
+	return names
+}
+
+// tlsConfig returns the config verifying host with the CA certificate of
+// cacert, and presenting the client certificate of cert and key if given
+func tlsConfig(host, cacert, cert, key string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host}
+	if cacert != "" {
+		pem, err := ioutil.ReadFile(cacert)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", cacert)
+		}
+	}
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
+
+func main() {
+	host := flag.String("h", "127.0.0.1", "server hostname")
+	port := flag.Int("p", 6380, "server port")
+	db := flag.Int("n", 0, "database number")
+	raw := flag.Bool("raw", false, "use raw formatting for replies")
+	user := flag.String("user", "", "acl user of AUTH, the default user when empty")
+	password := flag.String("a", "", "password of AUTH")
+	useTLS := flag.Bool("tls", false, "connect with tls")
+	cacert := flag.String("cacert", "", "CA certificate file verifying the server, the system roots when empty")
+	cert := flag.String("cert", "", "client certificate file of mutual tls")
+	key := flag.String("key", "", "private key file of the client certificate")
+	flag.Parse()
+
+	cli := &client{
+		addr:     net.JoinHostPort(*host, strconv.Itoa(*port)),
+		db:       *db,
+		user:     *user,
+		password: *password,
+	}
+	if *useTLS {
+		var err error
+		cli.tlsConfig, err = tlsConfig(*host, *cacert, *cert, *key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid tls config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := cli.connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect to mem-cache at %s: %v\n", cli.addr, err)
+		os.Exit(1)
+	}
+	defer cli.close()
+
+	if flag.NArg() > 0 {
+		v, err := cli.do(flag.Args()...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(formatValue(v, *raw))
+		if v.Type == resp.Error {
+			os.Exit(1)
+		}
+		return
+	}
+	repl(cli, *raw)
+}
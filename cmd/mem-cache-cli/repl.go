@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const historyFile = ".mem-cache-cli_history"
+const maxHistory = 1000
+
+func repl(cli *client, raw bool) {
+	names := cli.commandNames()
+	sort.Strings(names)
+	ed := newEditor(os.Stdin, os.Stdout)
+	ed.complete = func(prefix string) []string {
+		var res []string
+		for _, name := range names {
+			if strings.HasPrefix(name, strings.ToUpper(prefix)) {
+				res = append(res, name)
+			}
+		}
+		return res
+	}
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, historyFile)
+		ed.history = loadHistory(historyPath)
+	}
+
+	for {
+		prompt := cli.addr
+		if cli.db != 0 {
+			prompt += fmt.Sprintf("[%d]", cli.db)
+		}
+		line, err := ed.readLine(prompt + "> ")
+		if err == errInterrupt {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Println("Invalid argument(s)")
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !sensitiveCommand(args) {
+			ed.addHistory(line)
+			if historyPath != "" {
+				saveHistory(historyPath, ed.history)
+			}
+		}
+		cmd := strings.ToLower(args[0])
+		if cmd == "exit" || cmd == "quit" {
+			break
+		}
+		v, err := cli.do(args...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
+		fmt.Print(formatValue(v, raw))
+	}
+}
+
+// sensitiveCommand reports whether args carry a password, like redis-cli
+// such commands are kept out of the history
+func sensitiveCommand(args []string) bool {
+	switch strings.ToLower(args[0]) {
+	case "auth":
+		return true
+	case "acl":
+		return len(args) > 1 && strings.EqualFold(args[1], "setuser")
+	case "hello":
+		for _, arg := range args[1:] {
+			if strings.EqualFold(arg, "auth") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func loadHistory(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var history []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	return history
+}
+
+func saveHistory(path string, history []string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, line := range history {
+		w.WriteString(line)
+		w.WriteString("\n")
+	}
+	w.Flush()
+}
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes")
+
+// splitArgs splits a line like redis-cli, arguments can be quoted with
+// double quotes supporting \n \r \t \\ \" \xhh escapes, or single quotes
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	rd := strings.NewReader(line)
+	for {
+		c, err := skipSpaces(rd)
+		if err == io.EOF {
+			return args, nil
+		}
+		var sb strings.Builder
+		for {
+			switch {
+			case c == '"':
+				if err := readDoubleQuoted(rd, &sb); err != nil {
+					return nil, err
+				}
+			case c == '\'':
+				if err := readSingleQuoted(rd, &sb); err != nil {
+					return nil, err
+				}
+			default:
+				sb.WriteByte(c)
+			}
+			c, err = rd.ReadByte()
+			if err == io.EOF || c == ' ' || c == '\t' {
+				break
+			}
+		}
+		args = append(args, sb.String())
+		if err == io.EOF {
+			return args, nil
+		}
+	}
+}
+
+func skipSpaces(rd *strings.Reader) (byte, error) {
+	for {
+		c, err := rd.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' {
+			return c, nil
+		}
+	}
+}
+
+func readDoubleQuoted(rd *strings.Reader, sb *strings.Builder) error {
+	for {
+		c, err := rd.ReadByte()
+		if err != nil {
+			return errUnbalancedQuotes
+		}
+		if c == '"' {
+			return nil
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		c, err = rd.ReadByte()
+		if err != nil {
+			return errUnbalancedQuotes
+		}
+		switch c {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'x':
+			var hex [2]byte
+			if _, err := io.ReadFull(rd, hex[:]); err != nil {
+				return errUnbalancedQuotes
+			}
+			var b byte
+			if _, err := fmt.Sscanf(string(hex[:]), "%02x", &b); err != nil {
+				sb.WriteString("x")
+				sb.Write(hex[:])
+				continue
+			}
+			sb.WriteByte(b)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func readSingleQuoted(rd *strings.Reader, sb *strings.Builder) error {
+	for {
+		c, err := rd.ReadByte()
+		if err != nil {
+			return errUnbalancedQuotes
+		}
+		if c == '\'' {
+			return nil
+		}
+		if c == '\\' {
+			next, err := rd.ReadByte()
+			if err != nil {
+				return errUnbalancedQuotes
+			}
+			if next != '\'' {
+				sb.WriteByte(c)
+			}
+			c = next
+		}
+		sb.WriteByte(c)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw is only supported on linux, lines are read without editing elsewhere
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("raw mode is not supported")
+}
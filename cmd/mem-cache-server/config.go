@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wangyanga9/mem-cache/cache"
+	"github.com/wangyanga9/mem-cache/server"
+)
+
+// config is read from a redis.conf like file, one "name value" per line
+type config struct {
+	bind string
+	// port of the redis protocol, 0 means disabled
+	port int
+	// port of the redis protocol over tls, 0 means disabled
+	tlsPort int
+	tls     server.TLSConf
+	// authenticate tls clients as the acl user named by the CN of the certificate
+	tlsCertUser bool
+	// port of the memcached text protocol, 0 means disabled
+	memcachedPort int
+	// port of the http json api, 0 means disabled
+	httpPort int
+	cache    cache.CacheConf
+	// acl rules of the users, the first one is the user name
+	users [][]string
+}
+
+func defaultConfig() *config {
+	return &config{
+		bind: "127.0.0.1",
+		port: 6380,
+		cache: cache.CacheConf{
+			MaxSize: 100000,
+		},
+	}
+}
+
+func (conf *config) addr() string {
+	return net.JoinHostPort(conf.bind, strconv.Itoa(conf.port))
+}
+
+func (conf *config) tlsAddr() string {
+	return net.JoinHostPort(conf.bind, strconv.Itoa(conf.tlsPort))
+}
+
+func (conf *config) memcachedAddr() string {
+	return net.JoinHostPort(conf.bind, strconv.Itoa(conf.memcachedPort))
+}
+
+func (conf *config) httpAddr() string {
+	return net.JoinHostPort(conf.bind, strconv.Itoa(conf.httpPort))
+}
+
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseConfig(f)
+}
+
+func parseConfig(rd io.Reader) (*config, error) {
+	conf := defaultConfig()
+	scanner := bufio.NewScanner(rd)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if strings.ToLower(fields[0]) == "user" && len(fields) >= 2 {
+			// user <name> [rule ...] like redis.conf
+			conf.users = append(conf.users, fields[1:])
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("config line %d: need a name and a value", lineNum)
+		}
+		err := conf.set(strings.ToLower(fields[0]), fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("config line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if conf.memcachedPort != 0 && len(conf.users) > 0 {
+		// the memcached text protocol has no authentication to check the acl with
+		return nil, errors.New("memcached-port can not be used with requirepass or user")
+	}
+	return conf, nil
+}
+
+func (conf *config) set(name, value string) error {
+	var err error
+	switch name {
+	case "bind":
+		conf.bind = value
+	case "port":
+		conf.port, err = strconv.Atoi(value)
+	case "tls-port":
+		conf.tlsPort, err = strconv.Atoi(value)
+	case "tls-cert-file":
+		conf.tls.CertFile = value
+	case "tls-key-file":
+		conf.tls.KeyFile = value
+	case "tls-ca-cert-file":
+		conf.tls.CACertFile = value
+	case "tls-min-version":
+		_, err = server.ParseTLSVersion(value)
+		conf.tls.MinVersion = value
+	case "tls-auth-clients-user":
+		switch strings.ToLower(value) {
+		case "cn":
+			conf.tlsCertUser = true
+		case "off":
+			conf.tlsCertUser = false
+		default:
+			err = fmt.Errorf("need cn or off")
+		}
+	case "memcached-port":
+		conf.memcachedPort, err = strconv.Atoi(value)
+	case "http-port":
+		conf.httpPort, err = strconv.Atoi(value)
+	case "databases":
+		conf.cache.Databases, err = strconv.Atoi(value)
+	case "maxsize":
+		conf.cache.MaxSize, err = strconv.Atoi(value)
+	case "ttl-period-ms":
+		conf.cache.TtlPeriodMillSecond, err = strconv.Atoi(value)
+	case "maxmemory":
+		conf.cache.MaxMemoryBytes, err = parseMemory(value)
+	case "maxmemory-policy":
+		conf.cache.MaxMemoryPolicy = cache.EvictionPolicy(strings.ToLower(value))
+	case "maxmemory-samples":
+		conf.cache.MaxMemorySamples, err = strconv.Atoi(value)
+	case "lfu-log-factor":
+		conf.cache.LfuLogFactor, err = strconv.Atoi(value)
+	case "lfu-decay-time":
+		conf.cache.LfuDecayTime, err = strconv.Atoi(value)
+		// 0 never decays like redis, it is the default in CacheConf
+		if err == nil && conf.cache.LfuDecayTime == 0 {
+			conf.cache.LfuDecayTime = cache.LfuNeverDecay
+		}
+	case "pubsub-buffer-size":
+		conf.cache.PubSubBufferSize, err = strconv.Atoi(value)
+	case "pubsub-slow-policy":
+		conf.cache.PubSubSlowPolicy = cache.SlowSubscriberPolicy(strings.ToLower(value))
+	case "notify-keyspace-events":
+		conf.cache.NotifyKeyspaceEvents = strings.Trim(value, "\"")
+	case "requirepass":
+		conf.users = append(conf.users, []string{cache.DefaultUser, "resetpass", ">" + value})
+	case "slowlog-log-slower-than":
+		conf.cache.SlowLogSlowerThan, err = strconv.Atoi(value)
+	case "slowlog-max-len":
+		conf.cache.SlowLogMaxLen, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown config %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", name, value)
+	}
+	return nil
+}
+
+// parseMemory parses bytes with an optional unit like redis, 1k = 1000, 1kb = 1024
+func parseMemory(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		mul    int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	}
+	value = strings.ToLower(value)
+	mul := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			mul = unit.mul
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * mul, nil
+}
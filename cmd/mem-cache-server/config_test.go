@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+func TestParseConfig(t *testing.T) {
+	conf, err := parseConfig(strings.NewReader(`
+# comment
+bind 0.0.0.0
+port 7000
+tls-port 7001
+tls-cert-file server.crt
+tls-key-file server.key
+tls-ca-cert-file ca.crt
+tls-min-version TLSv1.3
+tls-auth-clients-user CN
+memcached-port 11211
+http-port 8080
+databases 4
+maxsize 1000
+ttl-period-ms 50
+maxmemory 100mb
+maxmemory-policy allkeys-LRU
+pubsub-slow-policy disconnect
+notify-keyspace-events "Ex"
+slowlog-log-slower-than -1
+slowlog-max-len 64
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if conf.addr() != "0.0.0.0:7000" {
+		t.Fatal("addr error")
+	}
+	if conf.tlsAddr() != "0.0.0.0:7001" || conf.tls.CertFile != "server.crt" || conf.tls.KeyFile != "server.key" ||
+		conf.tls.CACertFile != "ca.crt" || conf.tls.MinVersion != "TLSv1.3" || !conf.tlsCertUser {
+		t.Fatal("tls config error")
+	}
+	if conf.memcachedAddr() != "0.0.0.0:11211" {
+		t.Fatal("memcached addr error")
+	}
+	if conf.httpAddr() != "0.0.0.0:8080" {
+		t.Fatal("http addr error")
+	}
+	if conf.cache.Databases != 4 || conf.cache.MaxSize != 1000 || conf.cache.TtlPeriodMillSecond != 50 {
+		t.Fatal("cache config error")
+	}
+	if conf.cache.MaxMemoryBytes != 100<<20 || conf.cache.MaxMemoryPolicy != cache.AllKeysLRU {
+		t.Fatal("memory config error")
+	}
+	if conf.cache.PubSubSlowPolicy != cache.DisconnectSubscriber || conf.cache.NotifyKeyspaceEvents != "Ex" {
+		t.Fatal("pubsub config error")
+	}
+	if conf.cache.SlowLogSlowerThan != -1 || conf.cache.SlowLogMaxLen != 64 {
+		t.Fatal("slowlog config error")
+	}
+	conf, err = parseConfig(strings.NewReader(`
+requirepass foobared
+user alice on >secret ~app:* +@read
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(conf.users) != 2 || conf.users[0][2] != ">foobared" || conf.users[1][0] != "alice" || len(conf.users[1]) != 5 {
+		t.Fatal("users config error")
+	}
+	conf, err = parseConfig(strings.NewReader("lfu-decay-time 0"))
+	if err != nil || conf.cache.LfuDecayTime != cache.LfuNeverDecay {
+		t.Fatal("lfu-decay-time 0 should never decay")
+	}
+	_, err = parseConfig(strings.NewReader("port abc"))
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+	_, err = parseConfig(strings.NewReader("tls-min-version 1.0"))
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+	_, err = parseConfig(strings.NewReader("memcached-port 11211\nrequirepass foobared"))
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+	_, err = parseConfig(strings.NewReader("unknown 1"))
+	if err == nil {
+		t.Fatal("should have error,  but no error")
+	}
+}
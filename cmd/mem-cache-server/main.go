@@ -0,0 +1,108 @@
+// Command mem-cache-server serves a MemCache with the redis protocol over
+// plaintext and/or tls, and optionally the memcached text protocol, and the
+// http json api with the prometheus metrics at /metrics.
+//
+//	mem-cache-server [-config mem-cache.conf]
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/wangyanga9/mem-cache/cache"
+	"github.com/wangyanga9/mem-cache/httpapi"
+	"github.com/wangyanga9/mem-cache/memcached"
+	"github.com/wangyanga9/mem-cache/metrics"
+	"github.com/wangyanga9/mem-cache/server"
+)
+
+func main() {
+	configPath := flag.String("config", "", "config file, default config is used when empty")
+	flag.Parse()
+
+	conf := defaultConfig()
+	if *configPath != "" {
+		var err error
+		conf, err = loadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+	}
+
+	c, err := cache.NewMemCache(&conf.cache)
+	if err != nil {
+		log.Fatalf("create cache: %v", err)
+	}
+	for _, user := range conf.users {
+		if err := c.ACLSetUser(user[0], user[1:]...).Err(); err != nil {
+			log.Fatalf("acl user %s: %v", user[0], err)
+		}
+	}
+	if conf.port == 0 && conf.tlsPort == 0 {
+		log.Fatalf("port and tls-port are both disabled")
+	}
+	srv := server.New(c)
+	srv.TLSClientCertUser = conf.tlsCertUser
+
+	errCh := make(chan error, 4)
+	log.Printf("mem-cache-server %s started, pid %d", server.Version, os.Getpid())
+	if conf.port != 0 {
+		go func() {
+			errCh <- srv.ListenAndServe(conf.addr())
+		}()
+		log.Printf("listening on %s", conf.addr())
+	}
+	if conf.tlsPort != 0 {
+		tlsConfig, err := conf.tls.Config()
+		if err != nil {
+			log.Fatalf("load tls config: %v", err)
+		}
+		go func() {
+			errCh <- srv.ListenAndServeTLS(conf.tlsAddr(), tlsConfig)
+		}()
+		log.Printf("tls listening on %s", conf.tlsAddr())
+	}
+
+	var mcSrv *memcached.Server
+	if conf.memcachedPort != 0 {
+		mcSrv = memcached.New(c)
+		go func() {
+			errCh <- mcSrv.ListenAndServe(conf.memcachedAddr())
+		}()
+		log.Printf("memcached protocol listening on %s", conf.memcachedAddr())
+	}
+
+	var httpSrv *http.Server
+	if conf.httpPort != 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", httpapi.RequireAuth(c, metrics.New(c)))
+		mux.Handle("/", httpapi.New(c))
+		httpSrv = &http.Server{Addr: conf.httpAddr(), Handler: mux}
+		go func() {
+			errCh <- httpSrv.ListenAndServe()
+		}()
+		log.Printf("http api listening on %s", conf.httpAddr())
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-sigCh:
+		log.Printf("received %s, shutting down", sig)
+	case err := <-errCh:
+		log.Fatalf("serve: %v", err)
+	}
+	srv.Close()
+	if mcSrv != nil {
+		mcSrv.Close()
+	}
+	if httpSrv != nil {
+		httpSrv.Close()
+	}
+	c.Close()
+	log.Printf("mem-cache-server is now ready to exit, bye bye")
+}
@@ -1,3 +1,3 @@
 module github.com/wangyanga9/mem-cache
 
-go 1.12
+go 1.13
@@ -0,0 +1,324 @@
+// Package httpapi exposes a MemCache as a JSON REST API, for browser tools
+// and debugging with curl
+//
+//	GET|PUT|DELETE /keys/{key}
+//	GET|PUT|DELETE /hash/{key}/{field}
+//	GET|PUT|DELETE /set/{key}
+//
+// The handler can be mounted into an existing mux with http.StripPrefix.
+// Requests are authenticated with HTTP basic auth against the acl users of
+// the cache, a request without credentials is the default user.
+//
+// Values are JSON strings when they are valid UTF-8, other values are base64
+// encoded and the response has "encoding": "base64". A PUT body is stored as
+// it is, so binary values can be sent.
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// maxBodySize limits the value read from a PUT body
+const maxBodySize = 1 << 20
+
+// statusInsufficientStorage is 507, the limit of the cache is reached
+const statusInsufficientStorage = 507
+
+type Handler struct {
+	cache *cache.MemCache
+}
+
+// New returns a handler of c, the db query parameter selects another database
+func New(c *cache.MemCache) *Handler {
+	return &Handler{cache: c}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	RequireAuth(h.cache, http.HandlerFunc(h.serve)).ServeHTTP(w, r)
+}
+
+// RequireAuth authenticates the requests of next with HTTP basic auth
+// against the acl users of c, the user is passed to c in the request context
+// with cache.WithClientInfo so the commands are checked by the acl
+func RequireAuth(c *cache.MemCache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			user, password = cache.DefaultUser, ""
+		}
+		if err := c.ACLAuth(user, password).Err(); err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="mem-cache"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		ctx := cache.WithClientInfo(r.Context(), cache.ClientInfo{Addr: r.RemoteAddr, User: user})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	segments, err := splitPath(r.URL.EscapedPath())
+	if err != nil || len(segments) < 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	db := h.cache
+	if v := r.URL.Query().Get("db"); v != "" {
+		index, err := strconv.Atoi(v)
+		if err == nil {
+			db, err = h.cache.DB(index)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid db")
+			return
+		}
+	}
+	switch {
+	case segments[0] == "keys" && len(segments) == 2:
+		h.serveString(w, r, db, segments[1])
+	case segments[0] == "hash" && len(segments) == 3:
+		h.serveHash(w, r, db, segments[1], segments[2])
+	case segments[0] == "set" && len(segments) == 2:
+		h.serveSet(w, r, db, segments[1])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// splitPath unescapes every segment, so a key with "/" can be sent as %2F
+func splitPath(path string) ([]string, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range segments {
+		s, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = s
+	}
+	return segments, nil
+}
+
+// GET returns the value, PUT sets the body as value, DELETE deletes the key of any type
+func (h *Handler) serveString(w http.ResponseWriter, r *http.Request, db *cache.MemCache, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		val, err := db.GetContext(r.Context(), key).Result()
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, withValue(map[string]interface{}{"key": key}, val))
+	case http.MethodPut:
+		ttl, ok := parseTTL(w, r)
+		if !ok {
+			return
+		}
+		val, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		var err error
+		if ttl > 0 {
+			err = db.SetEXContext(r.Context(), key, val, ttl).Err()
+		} else {
+			err = db.SetContext(r.Context(), key, val).Err()
+		}
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "ok": true})
+	case http.MethodDelete:
+		h.deleteKey(w, r, db, key)
+	default:
+		writeMethodNotAllowed(w, "GET, PUT, DELETE")
+	}
+}
+
+// GET returns the value of field, PUT sets the body as value of field, DELETE deletes field
+func (h *Handler) serveHash(w http.ResponseWriter, r *http.Request, db *cache.MemCache, key, field string) {
+	switch r.Method {
+	case http.MethodGet:
+		val, err := db.HGetContext(r.Context(), key, field).Result()
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, withValue(map[string]interface{}{"key": key, "field": field}, val))
+	case http.MethodPut:
+		ttl, ok := parseTTL(w, r)
+		if !ok {
+			return
+		}
+		val, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		var added int
+		var err error
+		if ttl > 0 {
+			added, err = db.HSetEXContext(r.Context(), key, field, val, ttl).Result()
+		} else {
+			added, err = db.HSetContext(r.Context(), key, field, val).Result()
+		}
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "field": field, "added": added})
+	case http.MethodDelete:
+		deleted, err := db.HDelContext(r.Context(), key, field).Result()
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		if deleted == 0 {
+			writeError(w, http.StatusNotFound, cache.Nil.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "field": field, "deleted": deleted})
+	default:
+		writeMethodNotAllowed(w, "GET, PUT, DELETE")
+	}
+}
+
+// GET checks the member query parameter, PUT adds the members of a JSON
+// array body, DELETE deletes the key
+func (h *Handler) serveSet(w http.ResponseWriter, r *http.Request, db *cache.MemCache, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		member := r.URL.Query().Get("member")
+		if member == "" {
+			writeError(w, http.StatusBadRequest, "member query parameter is required")
+			return
+		}
+		n, err := db.SIsMemberContext(r.Context(), key, member).Result()
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "member": member, "exists": n == 1})
+	case http.MethodPut:
+		ttl, ok := parseTTL(w, r)
+		if !ok {
+			return
+		}
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		var members []string
+		if err := json.Unmarshal(body, &members); err != nil || len(members) == 0 {
+			writeError(w, http.StatusBadRequest, "body should be a JSON array of members")
+			return
+		}
+		var added int
+		var err error
+		if ttl > 0 {
+			added, err = db.SAddEXContext(r.Context(), key, ttl, members...).Result()
+		} else {
+			added, err = db.SAddContext(r.Context(), key, members...).Result()
+		}
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "added": added})
+	case http.MethodDelete:
+		h.deleteKey(w, r, db, key)
+	default:
+		writeMethodNotAllowed(w, "GET, PUT, DELETE")
+	}
+}
+
+func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request, db *cache.MemCache, key string) {
+	deleted, err := db.DelContext(r.Context(), key).Result()
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, cache.Nil.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "deleted": deleted})
+}
+
+// withValue adds val to fields, base64 encoded if it is not valid UTF-8
+func withValue(fields map[string]interface{}, val []byte) map[string]interface{} {
+	if utf8.Valid(val) {
+		fields["value"] = string(val)
+	} else {
+		fields["value"] = base64.StdEncoding.EncodeToString(val)
+		fields["encoding"] = "base64"
+	}
+	return fields
+}
+
+// parseTTL reads the ttl query parameter in seconds
+func parseTTL(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("ttl")
+	if v == "" {
+		return 0, true
+	}
+	ttl, err := strconv.Atoi(v)
+	if err != nil || ttl <= 0 {
+		writeError(w, http.StatusBadRequest, "ttl should be a positive integer")
+		return 0, false
+	}
+	return ttl, true
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return nil, false
+	}
+	return body, true
+}
+
+// writeCacheError maps errors of MemCache to status codes
+func writeCacheError(w http.ResponseWriter, err error) {
+	switch {
+	case err == cache.Nil:
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, cache.ErrWrongType):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, cache.ErrNoPermission):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, cache.ErrLimitExceeded):
+		writeError(w, statusInsufficientStorage, err.Error())
+	case errors.Is(err, cache.ErrInvalidArgument), errors.Is(err, cache.ErrWrongArgCount):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, cache.ErrClosed):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
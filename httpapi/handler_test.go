@@ -0,0 +1,188 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+func newTestHandler(t *testing.T, conf *cache.CacheConf) (http.Handler, *cache.MemCache) {
+	c, err := cache.NewMemCache(conf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/cache/", http.StripPrefix("/cache", New(c)))
+	return mux, c
+}
+
+func do(t *testing.T, h http.Handler, method, target, body string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal("decode response error:", rec.Body.String())
+	}
+	return rec.Code, res
+}
+
+func TestKeys(t *testing.T) {
+	h, c := newTestHandler(t, &cache.CacheConf{MaxSize: 10})
+	defer c.Close()
+	if code, _ := do(t, h, "GET", "/cache/keys/test1", ""); code != http.StatusNotFound {
+		t.Fatal("get not exist key should be 404, code=", code)
+	}
+	if code, _ := do(t, h, "PUT", "/cache/keys/a%2Fb?ttl=100", "value1"); code != http.StatusOK {
+		t.Fatal("put error, code=", code)
+	}
+	code, res := do(t, h, "GET", "/cache/keys/a%2Fb", "")
+	if code != http.StatusOK || res["value"] != "value1" || res["key"] != "a/b" {
+		t.Fatal("get error, res=", res)
+	}
+	if code, _ := do(t, h, "PUT", "/cache/keys/test1?ttl=abc", "value1"); code != http.StatusBadRequest {
+		t.Fatal("invalid ttl should be 400, code=", code)
+	}
+	if code, _ := do(t, h, "DELETE", "/cache/keys/a%2Fb", ""); code != http.StatusOK {
+		t.Fatal("delete error, code=", code)
+	}
+	if code, _ := do(t, h, "DELETE", "/cache/keys/a%2Fb", ""); code != http.StatusNotFound {
+		t.Fatal("delete not exist key should be 404, code=", code)
+	}
+	if code, _ := do(t, h, "POST", "/cache/keys/test1", ""); code != http.StatusMethodNotAllowed {
+		t.Fatal("post should be 405, code=", code)
+	}
+	if code, _ := do(t, h, "GET", "/cache/unknown/test1", ""); code != http.StatusNotFound {
+		t.Fatal("unknown path should be 404, code=", code)
+	}
+	if code, _ := do(t, h, "PUT", "/cache/keys/test1?db=1", "db1"); code != http.StatusOK {
+		t.Fatal("put db 1 error, code=", code)
+	}
+	if _, err := c.Get("test1").Result(); err != cache.Nil {
+		t.Fatal("db query parameter error")
+	}
+}
+
+func TestHashAndSet(t *testing.T) {
+	h, c := newTestHandler(t, &cache.CacheConf{MaxSize: 10})
+	defer c.Close()
+	code, res := do(t, h, "PUT", "/cache/hash/h1/f1", "v1")
+	if code != http.StatusOK || res["added"] != float64(1) {
+		t.Fatal("hash put error, res=", res)
+	}
+	code, res = do(t, h, "GET", "/cache/hash/h1/f1", "")
+	if code != http.StatusOK || res["value"] != "v1" {
+		t.Fatal("hash get error, res=", res)
+	}
+	if code, _ := do(t, h, "GET", "/cache/hash/h1/f2", ""); code != http.StatusNotFound {
+		t.Fatal("get not exist field should be 404, code=", code)
+	}
+	if code, _ := do(t, h, "GET", "/cache/keys/h1", ""); code != http.StatusConflict {
+		t.Fatal("wrong type should be 409, code=", code)
+	}
+	if code, _ := do(t, h, "DELETE", "/cache/hash/h1/f1", ""); code != http.StatusOK {
+		t.Fatal("hash delete error, code=", code)
+	}
+
+	code, res = do(t, h, "PUT", "/cache/set/s1", `["m1","m2","m1"]`)
+	if code != http.StatusOK || res["added"] != float64(2) {
+		t.Fatal("set put error, res=", res)
+	}
+	code, res = do(t, h, "GET", "/cache/set/s1?member=m2", "")
+	if code != http.StatusOK || res["exists"] != true {
+		t.Fatal("set get error, res=", res)
+	}
+	if code, _ := do(t, h, "PUT", "/cache/set/s1", "m1"); code != http.StatusBadRequest {
+		t.Fatal("invalid body should be 400, code=", code)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	h, c := newTestHandler(t, &cache.CacheConf{MaxSize: 1})
+	defer c.Close()
+	do(t, h, "PUT", "/cache/keys/test1", "value1")
+	if code, _ := do(t, h, "PUT", "/cache/keys/test2", "value2"); code != 507 {
+		t.Fatal("limit should be 507, code=", code)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	h, c := newTestHandler(t, &cache.CacheConf{MaxSize: 10})
+	defer c.Close()
+	c.ACLSetUser(cache.DefaultUser, "resetpass", ">adminpw")
+	c.ACLSetUser("reader", "on", ">readpw", "~*", "+@read")
+	if code, _ := do(t, h, "PUT", "/cache/keys/test1", "value1"); code != http.StatusUnauthorized {
+		t.Fatal("put without credentials should be 401, code=", code)
+	}
+	if _, err := c.Get("test1").Result(); err != cache.Nil {
+		t.Fatal("rejected put should not set the key")
+	}
+	req := httptest.NewRequest("PUT", "/cache/keys/test1", strings.NewReader("value1"))
+	req.SetBasicAuth("default", "wrong")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatal("put with a wrong password should be 401, code=", rec.Code)
+	}
+	req = httptest.NewRequest("PUT", "/cache/keys/test1", strings.NewReader("value1"))
+	req.SetBasicAuth("default", "adminpw")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatal("put with credentials error, code=", rec.Code)
+	}
+	req = httptest.NewRequest("PUT", "/cache/keys/test1", strings.NewReader("value2"))
+	req.SetBasicAuth("reader", "readpw")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatal("put of a read only user should be 403, code=", rec.Code)
+	}
+	req = httptest.NewRequest("GET", "/cache/keys/test1", nil)
+	req.SetBasicAuth("reader", "readpw")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "value1") {
+		t.Fatal("get of a read only user error, code=", rec.Code)
+	}
+	// a put with ttl needs set and expire, not exec
+	c.ACLSetUser("writer", "on", ">writepw", "~*", "+set", "+expire")
+	req = httptest.NewRequest("PUT", "/cache/keys/test2?ttl=100", strings.NewReader("value2"))
+	req.SetBasicAuth("writer", "writepw")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatal("put with ttl of a set and expire user error, code=", rec.Code)
+	}
+}
+
+func TestBinaryValue(t *testing.T) {
+	h, c := newTestHandler(t, &cache.CacheConf{MaxSize: 10})
+	defer c.Close()
+	if code, _ := do(t, h, "PUT", "/cache/keys/bin?ttl=100", "\xff\x00a"); code != http.StatusOK {
+		t.Fatal("put binary error, code=", code)
+	}
+	code, res := do(t, h, "GET", "/cache/keys/bin", "")
+	if code != http.StatusOK || res["value"] != "/wBh" || res["encoding"] != "base64" {
+		t.Fatal("binary value should be base64, res=", res)
+	}
+	do(t, h, "PUT", "/cache/keys/text", "héllo")
+	code, res = do(t, h, "GET", "/cache/keys/text", "")
+	if _, ok := res["encoding"]; code != http.StatusOK || ok || res["value"] != "héllo" {
+		t.Fatal("utf-8 value should be a string, res=", res)
+	}
+	// the ttl is set in the same command, a failed put sets nothing
+	if code, _ := do(t, h, "PUT", "/cache/hash/text/field1?ttl=100", "v"); code != http.StatusConflict {
+		t.Fatal("put with ttl on another type should be 409, code=", code)
+	}
+	if val, err := c.Get("text").Result(); err != nil || string(val) != "héllo" {
+		t.Fatal("failed put should not change the value")
+	}
+	if expires := c.Stats().Keyspace[0].Expires; expires != 1 {
+		t.Fatal("failed put should not set the ttl, expires=", expires)
+	}
+}
@@ -0,0 +1,360 @@
+package memcached
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// exptime larger than this is an unix timestamp, else seconds from now
+const maxRelativeExptime = 60 * 60 * 24 * 30
+
+const maxKeyLen = 250
+
+// expireAt converts memcached exptime to the expire time of MemCache,
+// 0 never expires and negative expires at once
+func expireAt(exptime int64, now time.Time) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return now.Add(-time.Second)
+	case exptime <= maxRelativeExptime:
+		return now.Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *conn) reply(noreply bool, s string) {
+	if !noreply {
+		c.wr.WriteString(s)
+		c.wr.WriteString("\r\n")
+	}
+}
+
+// replyErr writes err of MemCache as a memcached error
+func (c *conn) replyErr(err error) {
+	switch {
+	case errors.Is(err, cache.ErrLimitExceeded):
+		c.reply(false, "SERVER_ERROR out of memory storing object")
+	case errors.Is(err, cache.ErrInvalidArgument):
+		c.reply(false, "CLIENT_ERROR "+err.Error())
+	default:
+		c.reply(false, "SERVER_ERROR "+err.Error())
+	}
+}
+
+func (c *conn) dispatch(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		c.reply(false, "ERROR")
+		return
+	}
+	switch cmd := fields[0]; cmd {
+	case "get", "gets":
+		c.get(fields[1:], cmd == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		c.store(cache.StoreMode(cmd), fields[1:])
+	case "delete":
+		c.delete(fields[1:])
+	case "incr", "decr":
+		c.incr(fields[1:], cmd == "decr")
+	case "touch":
+		c.touch(fields[1:])
+	case "flush_all":
+		c.flushAll(fields[1:])
+	case "stats":
+		c.statsCmd(fields[1:])
+	case "version":
+		c.reply(false, "VERSION "+Version)
+	case "verbosity":
+		c.reply(len(fields) > 2 && fields[len(fields)-1] == "noreply", "OK")
+	case "quit":
+		c.quit = true
+	default:
+		c.reply(false, "ERROR")
+	}
+}
+
+// noreply removes the optional noreply at the end of args
+func noreply(args []string) ([]string, bool) {
+	if n := len(args); n > 0 && args[n-1] == "noreply" {
+		return args[:n-1], true
+	}
+	return args, false
+}
+
+// get <key>*, gets <key>*
+func (c *conn) get(keys []string, withCas bool) {
+	if len(keys) == 0 {
+		c.reply(false, "ERROR")
+		return
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			c.reply(false, "CLIENT_ERROR bad command line format")
+			return
+		}
+	}
+	items, err := c.srv.cache.MCGet(keys...).Result()
+	if err != nil {
+		c.replyErr(err)
+		return
+	}
+	atomic.AddInt64(&c.srv.stats.cmdGet, int64(len(keys)))
+	atomic.AddInt64(&c.srv.stats.getHits, int64(len(items)))
+	atomic.AddInt64(&c.srv.stats.getMisses, int64(len(keys)-len(items)))
+	for _, item := range items {
+		if withCas {
+			fmt.Fprintf(c.wr, "VALUE %s %d %d %d\r\n", item.Key, item.Flags, len(item.Value), item.Cas)
+		} else {
+			fmt.Fprintf(c.wr, "VALUE %s %d %d\r\n", item.Key, item.Flags, len(item.Value))
+		}
+		c.wr.Write(item.Value)
+		c.wr.WriteString("\r\n")
+	}
+	c.reply(false, "END")
+}
+
+// <mode> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]\r\n<data>\r\n
+func (c *conn) store(mode cache.StoreMode, args []string) {
+	n := 4
+	if mode == cache.StoreCas {
+		n = 5
+	}
+	args, nr := noreply(args)
+	if len(args) != n || !validKey(args[0]) {
+		c.reply(false, "CLIENT_ERROR bad command line format")
+		return
+	}
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+	var casUnique uint64
+	var err4 error
+	if mode == cache.StoreCas {
+		casUnique, err4 = strconv.ParseUint(args[4], 10, 64)
+	}
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || size < 0 {
+		c.reply(false, "CLIENT_ERROR bad command line format")
+		return
+	}
+	if size > maxItemSize {
+		// swallow the data block
+		io.CopyN(ioutil.Discard, c.rd, int64(size)+2)
+		c.reply(false, "SERVER_ERROR object too large for cache")
+		return
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.rd, data); err != nil {
+		c.quit = true
+		return
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		c.reply(false, "CLIENT_ERROR bad data chunk")
+		return
+	}
+	atomic.AddInt64(&c.srv.stats.cmdSet, 1)
+	item := &cache.Item{Key: args[0], Value: data[:size], Flags: uint32(flags), Cas: casUnique}
+	res, err := c.srv.cache.MCStore(mode, item, expireAt(exptime, time.Now())).Result()
+	if err != nil {
+		c.replyErr(err)
+		return
+	}
+	switch res {
+	case cache.Stored:
+		if mode == cache.StoreCas {
+			atomic.AddInt64(&c.srv.stats.casHits, 1)
+		}
+		c.reply(nr, "STORED")
+	case cache.NotStored:
+		c.reply(nr, "NOT_STORED")
+	case cache.Exists:
+		atomic.AddInt64(&c.srv.stats.casBadval, 1)
+		c.reply(nr, "EXISTS")
+	case cache.NotFound:
+		atomic.AddInt64(&c.srv.stats.casMisses, 1)
+		c.reply(nr, "NOT_FOUND")
+	}
+}
+
+// delete <key> [0] [noreply]
+func (c *conn) delete(args []string) {
+	args, nr := noreply(args)
+	if len(args) == 2 && args[1] == "0" {
+		args = args[:1]
+	}
+	if len(args) != 1 || !validKey(args[0]) {
+		c.reply(false, "CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]")
+		return
+	}
+	res, err := c.srv.cache.MCDelete(args[0]).Result()
+	if err != nil {
+		c.replyErr(err)
+		return
+	}
+	if res == 0 {
+		atomic.AddInt64(&c.srv.stats.deleteMisses, 1)
+		c.reply(nr, "NOT_FOUND")
+		return
+	}
+	atomic.AddInt64(&c.srv.stats.deleteHits, 1)
+	c.reply(nr, "DELETED")
+}
+
+// incr|decr <key> <value> [noreply]
+func (c *conn) incr(args []string, decr bool) {
+	args, nr := noreply(args)
+	if len(args) != 2 || !validKey(args[0]) {
+		c.reply(false, "ERROR")
+		return
+	}
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		c.reply(false, "CLIENT_ERROR invalid numeric delta argument")
+		return
+	}
+	hits, misses := &c.srv.stats.incrHits, &c.srv.stats.incrMisses
+	var res *cache.BytesResult
+	if decr {
+		hits, misses = &c.srv.stats.decrHits, &c.srv.stats.decrMisses
+		res = c.srv.cache.MCDecr(args[0], delta)
+	} else {
+		res = c.srv.cache.MCIncr(args[0], delta)
+	}
+	val, err := res.Result()
+	if err == cache.Nil {
+		atomic.AddInt64(misses, 1)
+		c.reply(nr, "NOT_FOUND")
+		return
+	}
+	if errors.Is(err, cache.ErrInvalidArgument) {
+		c.reply(false, "CLIENT_ERROR cannot increment or decrement non-numeric value")
+		return
+	}
+	if err != nil {
+		c.replyErr(err)
+		return
+	}
+	atomic.AddInt64(hits, 1)
+	c.reply(nr, string(val))
+}
+
+// touch <key> <exptime> [noreply]
+func (c *conn) touch(args []string) {
+	args, nr := noreply(args)
+	if len(args) != 2 || !validKey(args[0]) {
+		c.reply(false, "ERROR")
+		return
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.reply(false, "CLIENT_ERROR invalid exptime argument")
+		return
+	}
+	atomic.AddInt64(&c.srv.stats.cmdTouch, 1)
+	res, err := c.srv.cache.MCTouch(args[0], expireAt(exptime, time.Now())).Result()
+	if err != nil {
+		c.replyErr(err)
+		return
+	}
+	if res == 0 {
+		atomic.AddInt64(&c.srv.stats.touchMisses, 1)
+		c.reply(nr, "NOT_FOUND")
+		return
+	}
+	atomic.AddInt64(&c.srv.stats.touchHits, 1)
+	c.reply(nr, "TOUCHED")
+}
+
+// flush_all [delay] [noreply], deletes the string keys, with delay they are
+// deleted after delay seconds
+func (c *conn) flushAll(args []string) {
+	args, nr := noreply(args)
+	if len(args) > 1 {
+		c.reply(false, "ERROR")
+		return
+	}
+	delay := 0
+	if len(args) == 1 {
+		var err error
+		delay, err = strconv.Atoi(args[0])
+		if err != nil || delay < 0 {
+			c.reply(false, "CLIENT_ERROR bad command line format")
+			return
+		}
+	}
+	atomic.AddInt64(&c.srv.stats.cmdFlush, 1)
+	if delay > 0 {
+		c.srv.flushAfter(time.Duration(delay) * time.Second)
+		c.reply(nr, "OK")
+		return
+	}
+	c.srv.flushAfter(0)
+	_, err := c.srv.cache.MCFlush().Result()
+	if err != nil {
+		c.replyErr(err)
+		return
+	}
+	c.reply(nr, "OK")
+}
+
+// stats without arguments, general-purpose statistics only
+func (c *conn) statsCmd(args []string) {
+	if len(args) != 0 {
+		c.reply(false, "ERROR")
+		return
+	}
+	items, _ := c.srv.cache.DBSize().Result()
+	st := &c.srv.stats
+	now := time.Now()
+	stat := func(name string, val interface{}) {
+		fmt.Fprintf(c.wr, "STAT %s %v\r\n", name, val)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(c.srv.started)/time.Second))
+	stat("time", now.Unix())
+	stat("version", Version)
+	stat("curr_connections", atomic.LoadInt64(&st.currConnections))
+	stat("total_connections", atomic.LoadInt64(&st.totalConnections))
+	stat("cmd_get", atomic.LoadInt64(&st.cmdGet))
+	stat("cmd_set", atomic.LoadInt64(&st.cmdSet))
+	stat("cmd_flush", atomic.LoadInt64(&st.cmdFlush))
+	stat("cmd_touch", atomic.LoadInt64(&st.cmdTouch))
+	stat("get_hits", atomic.LoadInt64(&st.getHits))
+	stat("get_misses", atomic.LoadInt64(&st.getMisses))
+	stat("delete_misses", atomic.LoadInt64(&st.deleteMisses))
+	stat("delete_hits", atomic.LoadInt64(&st.deleteHits))
+	stat("incr_misses", atomic.LoadInt64(&st.incrMisses))
+	stat("incr_hits", atomic.LoadInt64(&st.incrHits))
+	stat("decr_misses", atomic.LoadInt64(&st.decrMisses))
+	stat("decr_hits", atomic.LoadInt64(&st.decrHits))
+	stat("cas_misses", atomic.LoadInt64(&st.casMisses))
+	stat("cas_hits", atomic.LoadInt64(&st.casHits))
+	stat("cas_badval", atomic.LoadInt64(&st.casBadval))
+	stat("touch_hits", atomic.LoadInt64(&st.touchHits))
+	stat("touch_misses", atomic.LoadInt64(&st.touchMisses))
+	stat("curr_items", items)
+	c.reply(false, "END")
+}
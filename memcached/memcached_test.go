@@ -0,0 +1,176 @@
+package memcached
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+type client struct {
+	nc net.Conn
+	rd *bufio.Reader
+}
+
+func newTestServer(t *testing.T) (*client, *cache.MemCache, func()) {
+	c, err := cache.NewMemCache(&cache.CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	srv := New(c)
+	go srv.Serve(ln)
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &client{nc: nc, rd: bufio.NewReader(nc)}, c, func() {
+		srv.Close()
+		c.Close()
+	}
+}
+
+// do sends request and reads n lines of reply
+func (cli *client) do(t *testing.T, request string, n int) string {
+	if _, err := cli.nc.Write([]byte(request)); err != nil {
+		t.Fatal(err.Error())
+	}
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		line, err := cli.rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+func TestStorage(t *testing.T) {
+	cli, _, closeFunc := newTestServer(t)
+	defer closeFunc()
+	if res := cli.do(t, "add key1 5 0 3\r\nabc\r\n", 1); res != "STORED\r\n" {
+		t.Fatal("add result error, res=", res)
+	}
+	if res := cli.do(t, "add key1 5 0 3\r\nabc\r\n", 1); res != "NOT_STORED\r\n" {
+		t.Fatal("add exist key result error, res=", res)
+	}
+	if res := cli.do(t, "append key1 0 0 2\r\nde\r\n", 1); res != "STORED\r\n" {
+		t.Fatal("append result error, res=", res)
+	}
+	if res := cli.do(t, "get key1 notexist\r\n", 3); res != "VALUE key1 5 5\r\nabcde\r\nEND\r\n" {
+		t.Fatal("get result error, res=", res)
+	}
+	res := cli.do(t, "gets key1\r\n", 3)
+	fields := strings.Fields(strings.SplitN(res, "\r\n", 2)[0])
+	if len(fields) != 5 {
+		t.Fatal("gets result error, res=", res)
+	}
+	cas := fields[4]
+	if res := cli.do(t, "cas key1 0 0 1 "+cas+"0\r\nx\r\n", 1); res != "EXISTS\r\n" {
+		t.Fatal("cas mismatch result error, res=", res)
+	}
+	if res := cli.do(t, "cas key1 0 0 1 "+cas+"\r\nx\r\n", 1); res != "STORED\r\n" {
+		t.Fatal("cas result error, res=", res)
+	}
+	if res := cli.do(t, "set key1 0 0 3 noreply\r\nabc\r\nset key1 0 0 2\r\nab\r\n", 1); res != "STORED\r\n" {
+		t.Fatal("noreply result error, res=", res)
+	}
+	if res := cli.do(t, "set key1 0 0 2\r\nabc\r\n", 1); res != "CLIENT_ERROR bad data chunk\r\n" {
+		t.Fatal("bad data chunk result error, res=", res)
+	}
+}
+
+func TestIncrDeleteTouch(t *testing.T) {
+	cli, c, closeFunc := newTestServer(t)
+	defer closeFunc()
+	cli.do(t, "set num 0 0 2\r\n10\r\n", 1)
+	if res := cli.do(t, "incr num 5\r\n", 1); res != "15\r\n" {
+		t.Fatal("incr result error, res=", res)
+	}
+	if res := cli.do(t, "decr num 100\r\n", 1); res != "0\r\n" {
+		t.Fatal("decr result error, res=", res)
+	}
+	if res := cli.do(t, "incr notexist 1\r\n", 1); res != "NOT_FOUND\r\n" {
+		t.Fatal("incr not exist result error, res=", res)
+	}
+	if res := cli.do(t, "touch num 100\r\n", 1); res != "TOUCHED\r\n" {
+		t.Fatal("touch result error, res=", res)
+	}
+	if ttl := c.MCTouch("num", time.Now().Add(time.Hour)); ttl.Err() != nil {
+		t.Fatal(ttl.Err().Error())
+	}
+	if res := cli.do(t, "delete num\r\n", 1); res != "DELETED\r\n" {
+		t.Fatal("delete result error, res=", res)
+	}
+	if res := cli.do(t, "delete num\r\n", 1); res != "NOT_FOUND\r\n" {
+		t.Fatal("delete not exist result error, res=", res)
+	}
+	cli.do(t, "set key1 0 -1 1\r\na\r\n", 1)
+	if res := cli.do(t, "get key1\r\n", 1); res != "END\r\n" {
+		t.Fatal("negative exptime should expire at once, res=", res)
+	}
+	cli.do(t, "set key1 0 0 1\r\na\r\n", 1)
+	if res := cli.do(t, "flush_all\r\n", 1); res != "OK\r\n" {
+		t.Fatal("flush_all result error, res=", res)
+	}
+	if size, _ := c.DBSize().Result(); size != 0 {
+		t.Fatal("flush_all should delete all keys")
+	}
+	if res := cli.do(t, "bogus\r\n", 1); res != "ERROR\r\n" {
+		t.Fatal("unknown command result error, res=", res)
+	}
+}
+
+func TestExpireAt(t *testing.T) {
+	now := time.Unix(1000000000, 0)
+	if !expireAt(0, now).IsZero() {
+		t.Fatal("0 should never expire")
+	}
+	if !expireAt(10, now).Equal(now.Add(10 * time.Second)) {
+		t.Fatal("relative exptime error")
+	}
+	if !expireAt(2000000000, now).Equal(time.Unix(2000000000, 0)) {
+		t.Fatal("absolute exptime error")
+	}
+	if expireAt(-1, now).After(now) {
+		t.Fatal("negative exptime error")
+	}
+}
+
+func TestStringsOnly(t *testing.T) {
+	cli, c, closeFunc := newTestServer(t)
+	defer closeFunc()
+	c.HSet("hash1", "field1", []byte("v"))
+	c.SAdd("set1", "m")
+	if res := cli.do(t, "delete hash1\r\n", 1); res != "NOT_FOUND\r\n" {
+		t.Fatal("delete should not delete a hash, res=", res)
+	}
+	if res := cli.do(t, "touch set1 100\r\n", 1); res != "NOT_FOUND\r\n" {
+		t.Fatal("touch should not touch a set, res=", res)
+	}
+	cli.do(t, "set key1 0 0 1\r\na\r\n", 1)
+	if res := cli.do(t, "flush_all\r\n", 1); res != "OK\r\n" {
+		t.Fatal("flush_all result error, res=", res)
+	}
+	if size, _ := c.DBSize().Result(); size != 2 {
+		t.Fatal("flush_all should only delete string keys, size=", size)
+	}
+
+	// a later flush_all replaces the delayed one
+	if res := cli.do(t, "flush_all 1\r\n", 1); res != "OK\r\n" {
+		t.Fatal("flush_all with delay result error, res=", res)
+	}
+	cli.do(t, "flush_all 0\r\n", 1)
+	cli.do(t, "set key1 0 0 1\r\na\r\n", 1)
+	time.Sleep(1200 * time.Millisecond)
+	if res := cli.do(t, "get key1\r\n", 3); res != "VALUE key1 0 1\r\na\r\nEND\r\n" {
+		t.Fatal("replaced delayed flush_all should not run, res=", res)
+	}
+}
@@ -0,0 +1,217 @@
+// Package memcached serves the string values of a MemCache with the
+// memcached text protocol, for clients that only speak memcached
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// Version is reported by the version and stats commands
+const Version = "1.6.0-mem-cache"
+
+// maxItemSize is the value size limit, same as memcached default
+const maxItemSize = 1024 * 1024
+
+var ErrServerClosed = errors.New("memcached: Server closed")
+
+// counters of the stats command, int64 first to keep them aligned
+type stats struct {
+	cmdGet           int64
+	getHits          int64
+	getMisses        int64
+	cmdSet           int64
+	cmdTouch         int64
+	touchHits        int64
+	touchMisses      int64
+	deleteHits       int64
+	deleteMisses     int64
+	incrHits         int64
+	incrMisses       int64
+	decrHits         int64
+	decrMisses       int64
+	casHits          int64
+	casMisses        int64
+	casBadval        int64
+	cmdFlush         int64
+	currConnections  int64
+	totalConnections int64
+}
+
+type Server struct {
+	cache   *cache.MemCache
+	stats   stats
+	started time.Time
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	// pending flush_all with delay, replaced by a later flush_all
+	flushTimer *time.Timer
+}
+
+// New returns a server of the database c is bound to
+func New(c *cache.MemCache) *Server {
+	return &Server{
+		cache:     c,
+		started:   time.Now(),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve
+func (srv *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
+// Serve accepts connections on ln until Close is called, it always
+// returns a non-nil error, ErrServerClosed after Close
+func (srv *Server) Serve(ln net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.listeners[ln] = struct{}{}
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.listeners, ln)
+		srv.mu.Unlock()
+		ln.Close()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		srv.mu.Lock()
+		if srv.closed {
+			srv.mu.Unlock()
+			nc.Close()
+			return ErrServerClosed
+		}
+		srv.conns[nc] = struct{}{}
+		srv.wg.Add(1)
+		srv.mu.Unlock()
+		go func() {
+			defer srv.wg.Done()
+			srv.serveConn(nc)
+			srv.mu.Lock()
+			delete(srv.conns, nc)
+			srv.mu.Unlock()
+		}()
+	}
+}
+
+// Close closes all listeners and connections, and waits for the
+// connection goroutines to return, the MemCache is not closed
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return ErrServerClosed
+	}
+	srv.closed = true
+	if srv.flushTimer != nil {
+		srv.flushTimer.Stop()
+	}
+	for ln := range srv.listeners {
+		ln.Close()
+	}
+	for nc := range srv.conns {
+		nc.Close()
+	}
+	srv.mu.Unlock()
+	srv.wg.Wait()
+	return nil
+}
+
+// flushAfter replaces the pending flush_all with a flush after d, 0 only
+// cancels the pending one
+func (srv *Server) flushAfter(d time.Duration) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.flushTimer != nil {
+		srv.flushTimer.Stop()
+		srv.flushTimer = nil
+	}
+	if d > 0 && !srv.closed {
+		srv.flushTimer = time.AfterFunc(d, func() {
+			srv.cache.MCFlush()
+		})
+	}
+}
+
+// conn is the state of a client connection
+type conn struct {
+	srv  *Server
+	rd   *bufio.Reader
+	wr   *bufio.Writer
+	quit bool
+}
+
+func (srv *Server) serveConn(nc net.Conn) {
+	defer nc.Close()
+	atomic.AddInt64(&srv.stats.currConnections, 1)
+	atomic.AddInt64(&srv.stats.totalConnections, 1)
+	defer atomic.AddInt64(&srv.stats.currConnections, -1)
+
+	c := &conn{srv: srv, rd: bufio.NewReader(nc), wr: bufio.NewWriter(nc)}
+	for !c.quit {
+		line, err := c.readLine()
+		if err != nil {
+			if err == errLineTooLong {
+				c.wr.WriteString("CLIENT_ERROR line too long\r\n")
+				c.wr.Flush()
+			}
+			return
+		}
+		c.dispatch(line)
+		// flush once after the pipelined commands
+		if c.rd.Buffered() == 0 || c.quit {
+			if err := c.wr.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+var errLineTooLong = errors.New("line too long")
+
+// readLine reads a command line without the trailing \r\n
+func (c *conn) readLine() (string, error) {
+	line, err := c.rd.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", errLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return string(line), nil
+}
@@ -0,0 +1,141 @@
+// Package metrics exposes the statistics of a MemCache in the prometheus
+// text format, for scraping by prometheus
+//
+//	http.Handle("/metrics", metrics.New(cache))
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// contentType of the prometheus text format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type Handler struct {
+	cache *cache.MemCache
+}
+
+// New returns a handler of the statistics of all databases of c
+func New(c *cache.MemCache) *Handler {
+	return &Handler{cache: c}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	bw := bufio.NewWriter(w)
+	Write(bw, h.cache.Stats())
+	bw.Flush()
+}
+
+// Write writes stats in the prometheus text format
+func Write(w io.Writer, stats *cache.Stats) {
+	names := make([]string, 0, len(stats.Commands))
+	for name := range stats.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gauge(w, "memcache_uptime_seconds", "Seconds since the cache was created.", stats.Uptime.Seconds())
+	gauge(w, "memcache_used_memory_bytes", "Estimated memory of all databases.", stats.UsedMemory)
+	gauge(w, "memcache_max_memory_bytes", "Memory limit of every database, 0 means no limit.", stats.MaxMemory)
+
+	header(w, "memcache_keys", "Keys of the database.", "gauge")
+	for _, ks := range stats.Keyspace {
+		fmt.Fprintf(w, "memcache_keys{db=\"%d\"} %d\n", ks.DB, ks.Keys)
+	}
+	header(w, "memcache_expires", "Keys with ttl of the database.", "gauge")
+	for _, ks := range stats.Keyspace {
+		fmt.Fprintf(w, "memcache_expires{db=\"%d\"} %d\n", ks.DB, ks.Expires)
+	}
+
+	counter(w, "memcache_keyspace_hits_total", "Readonly commands finding the key.", stats.KeyspaceHits)
+	counter(w, "memcache_keyspace_misses_total", "Readonly commands not finding the key.", stats.KeyspaceMisses)
+	counter(w, "memcache_expired_keys_total", "Keys deleted because of ttl.", stats.ExpiredKeys)
+	counter(w, "memcache_evicted_keys_total", "Keys deleted by the eviction policy.", stats.EvictedKeys)
+	counter(w, "memcache_rejected_by_limit_total", "Commands rejected by the size or memory limit.", stats.RejectedByLimit)
+	counter(w, "memcache_expire_cycles_total", "Cycles of the active expiry.", stats.ExpireCycles)
+	counter(w, "memcache_expire_cycle_seconds_total", "Time spent in the cycles of the active expiry.", stats.ExpireCycleTime.Seconds())
+
+	header(w, "memcache_commands_total", "Calls of the command.", "counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "memcache_commands_total{command=\"%s\"} %d\n", escape(name), stats.Commands[name].Calls)
+	}
+	header(w, "memcache_command_errors_total", "Errors of the command, a miss is not an error.", "counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "memcache_command_errors_total{command=\"%s\"} %d\n", escape(name), stats.Commands[name].Errors)
+	}
+
+	types := make([]string, 0, len(stats.Errors))
+	for typ := range stats.Errors {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	header(w, "memcache_errors_total", "Errors by type.", "counter")
+	for _, typ := range types {
+		fmt.Fprintf(w, "memcache_errors_total{type=\"%s\"} %d\n", escape(typ), stats.Errors[typ])
+	}
+
+	header(w, "memcache_command_lock_wait_seconds", "Time waiting for the data lock.", "histogram")
+	for _, name := range names {
+		cs := stats.Commands[name]
+		histogram(w, "memcache_command_lock_wait_seconds", name, &cs.LockWait)
+	}
+	header(w, "memcache_command_exec_seconds", "Time running the command with the data lock held.", "histogram")
+	for _, name := range names {
+		cs := stats.Commands[name]
+		histogram(w, "memcache_command_exec_seconds", name, &cs.Exec)
+	}
+}
+
+func header(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func gauge(w io.Writer, name, help string, val interface{}) {
+	header(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %v\n", name, val)
+}
+
+func counter(w io.Writer, name, help string, val interface{}) {
+	header(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %v\n", name, val)
+}
+
+func histogram(w io.Writer, name, command string, h *cache.Histogram) {
+	command = escape(command)
+	cumulative := h.Cumulative()
+	for i, le := range cache.LatencyBuckets {
+		fmt.Fprintf(w, "%s_bucket{command=\"%s\",le=\"%s\"} %d\n", name, command, seconds(le), cumulative[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{command=\"%s\",le=\"+Inf\"} %d\n", name, command, h.Count)
+	fmt.Fprintf(w, "%s_sum{command=\"%s\"} %s\n", name, command, seconds(h.Sum))
+	fmt.Fprintf(w, "%s_count{command=\"%s\"} %d\n", name, command, h.Count)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+// escape a label value, \ " and newline are escaped in the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
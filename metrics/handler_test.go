@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+func TestHandler(t *testing.T) {
+	c, err := cache.NewMemCache(&cache.CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+	c.Set("test1", []byte("1"))
+	c.Get("test1")
+	c.Get("notexist")
+	c.HSet("test1", "field", []byte("1"))
+	c.Do(context.Background(), "notexist")
+
+	rec := httptest.NewRecorder()
+	New(c).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatal("metrics response error, code=", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE memcache_command_exec_seconds histogram\n",
+		`memcache_commands_total{command="get"} 2` + "\n",
+		`memcache_command_errors_total{command="hset"} 1` + "\n",
+		`memcache_errors_total{type="unknown_command"} 1` + "\n",
+		`memcache_errors_total{type="wrongtype"} 1` + "\n",
+		`memcache_command_exec_seconds_bucket{command="get",le="+Inf"} 2` + "\n",
+		`memcache_command_lock_wait_seconds_count{command="set"} 1` + "\n",
+		`memcache_keys{db="0"} 1` + "\n",
+		"memcache_keyspace_misses_total 1\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatal("metrics should contain", line, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	New(c).ServeHTTP(rec, httptest.NewRequest("POST", "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatal("post should not be allowed, code=", rec.Code)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if escape("a\"b\\c\nd") != `a\"b\\c\nd` {
+		t.Fatal("escape error")
+	}
+}
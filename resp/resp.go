@@ -0,0 +1,395 @@
+// Package resp reads and writes the redis serialization protocol, RESP2 and RESP3
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Type is the first byte of a RESP value
+type Type byte
+
+const (
+	SimpleString Type = '+'
+	Error        Type = '-'
+	Integer      Type = ':'
+	BulkString   Type = '$'
+	Array        Type = '*'
+	// RESP3 only
+	Null      Type = '_'
+	Boolean   Type = '#'
+	Double    Type = ','
+	BigNumber Type = '('
+	BulkError Type = '!'
+	Verbatim  Type = '='
+	Map       Type = '%'
+	Set       Type = '~'
+	Push      Type = '>'
+)
+
+// limits of a value read from network
+const (
+	maxBulkLen  = 512 * 1024 * 1024
+	maxArrayLen = 1024 * 1024
+	maxInline   = 64 * 1024
+	// nesting of aggregate values, a command is not nested at all
+	maxNesting = 7
+)
+
+var ErrProtocol = errors.New("Protocol error")
+
+// Value is a decoded RESP value, Str holds strings, errors, doubles and
+// big numbers, Elems holds arrays, sets, pushes and maps as key, value pairs
+type Value struct {
+	Type  Type
+	Str   []byte
+	Int   int64
+	Bool  bool
+	Elems []Value
+	// null bulk string or null array in RESP2, or RESP3 null
+	IsNull bool
+}
+
+func (v Value) String() string {
+	return string(v.Str)
+}
+
+type Reader struct {
+	rd *bufio.Reader
+}
+
+func NewReader(rd io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReader(rd)}
+}
+
+// Buffered returns the bytes that can be read without blocking,
+// used to flush the replies only after the last pipelined command
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.rd.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("%w: too big inline request", ErrProtocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: expected CRLF", ErrProtocol)
+	}
+	return line[:len(line)-2], nil
+}
+
+func parseInt(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid integer %q", ErrProtocol, b)
+	}
+	return n, nil
+}
+
+// ReadCommand reads a request, an array of bulk strings or an inline command
+func (r *Reader) ReadCommand() ([][]byte, error) {
+	for {
+		b, err := r.rd.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if Type(b[0]) == Array {
+			// elements of a command are bulk strings, nested values are rejected
+			v, err := r.readValue(1)
+			if err != nil {
+				return nil, err
+			}
+			args := make([][]byte, 0, len(v.Elems))
+			for _, elem := range v.Elems {
+				if elem.Type != BulkString || elem.IsNull {
+					return nil, fmt.Errorf("%w: expected bulk string", ErrProtocol)
+				}
+				args = append(args, elem.Str)
+			}
+			if len(args) == 0 {
+				continue
+			}
+			return args, nil
+		}
+		line, err := r.readInline()
+		if err != nil {
+			return nil, err
+		}
+		args, err := splitInline(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		return args, nil
+	}
+}
+
+func (r *Reader) readInline() ([]byte, error) {
+	var line []byte
+	for {
+		part, err := r.rd.ReadSlice('\n')
+		line = append(line, part...)
+		if len(line) > maxInline {
+			return nil, fmt.Errorf("%w: too big inline request", ErrProtocol)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// splitInline splits an inline command by spaces, double quoted arguments can
+// contain spaces, a quote without its closing quote is a protocol error
+func splitInline(line []byte) ([][]byte, error) {
+	var args [][]byte
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		var arg []byte
+		if line[i] == '"' {
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				arg = append(arg, line[i])
+				i++
+			}
+			if i == len(line) {
+				return nil, fmt.Errorf("%w: unbalanced quotes in request", ErrProtocol)
+			}
+			i++
+		} else {
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				arg = append(arg, line[i])
+				i++
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// ReadValue reads a RESP2 or RESP3 value, aggregates nested deeper than
+// maxNesting are a protocol error
+func (r *Reader) ReadValue() (Value, error) {
+	return r.readValue(maxNesting)
+}
+
+// readValue reads a value whose aggregates can nest depth levels
+func (r *Reader) readValue(depth int) (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	if len(line) == 0 {
+		return Value{}, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+	v := Value{Type: Type(line[0])}
+	body := line[1:]
+	switch v.Type {
+	case SimpleString, Error, Double, BigNumber:
+		v.Str = append([]byte(nil), body...)
+	case Integer:
+		v.Int, err = parseInt(body)
+	case Null:
+		v.IsNull = true
+	case Boolean:
+		v.Bool = len(body) == 1 && body[0] == 't'
+	case BulkString, BulkError, Verbatim:
+		var n int64
+		n, err = parseInt(body)
+		if err != nil {
+			break
+		}
+		if n < 0 {
+			v.IsNull = true
+			break
+		}
+		if n > maxBulkLen {
+			return v, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+		}
+		v.Str = make([]byte, n+2)
+		_, err = io.ReadFull(r.rd, v.Str)
+		if err != nil {
+			break
+		}
+		if v.Str[n] != '\r' || v.Str[n+1] != '\n' {
+			return v, fmt.Errorf("%w: expected CRLF", ErrProtocol)
+		}
+		v.Str = v.Str[:n]
+	case Array, Set, Push, Map:
+		var n int64
+		n, err = parseInt(body)
+		if err != nil {
+			break
+		}
+		if n < 0 {
+			v.IsNull = true
+			break
+		}
+		if n > maxArrayLen {
+			return v, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+		}
+		if depth == 0 {
+			return v, fmt.Errorf("%w: too deep nesting", ErrProtocol)
+		}
+		if v.Type == Map {
+			n *= 2
+		}
+		// grown as the elements arrive, a header alone allocates little
+		for i := int64(0); i < n; i++ {
+			var elem Value
+			elem, err = r.readValue(depth - 1)
+			if err != nil {
+				break
+			}
+			v.Elems = append(v.Elems, elem)
+		}
+	default:
+		return v, fmt.Errorf("%w: unknown type %q", ErrProtocol, line[0])
+	}
+	return v, err
+}
+
+// Writer encodes values in RESP2 or RESP3, types only in RESP3 are
+// downgraded like redis does when Proto is 2
+type Writer struct {
+	wr    *bufio.Writer
+	Proto int
+}
+
+func NewWriter(wr io.Writer) *Writer {
+	return &Writer{wr: bufio.NewWriter(wr), Proto: 2}
+}
+
+func (w *Writer) Flush() error {
+	return w.wr.Flush()
+}
+
+func (w *Writer) writeHeader(t Type, n int64) {
+	w.wr.WriteByte(byte(t))
+	w.wr.WriteString(strconv.FormatInt(n, 10))
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteSimple(s string) {
+	w.wr.WriteByte(byte(SimpleString))
+	w.wr.WriteString(s)
+	w.wr.WriteString("\r\n")
+}
+
+// WriteError writes an error, s starts with the error code like "ERR"
+func (w *Writer) WriteError(s string) {
+	w.wr.WriteByte(byte(Error))
+	w.wr.WriteString(s)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteInt(n int64) {
+	w.writeHeader(Integer, n)
+}
+
+func (w *Writer) WriteBulk(b []byte) {
+	w.writeHeader(BulkString, int64(len(b)))
+	w.wr.Write(b)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteBulkString(s string) {
+	w.WriteBulk([]byte(s))
+}
+
+// WriteNull writes a null bulk string in RESP2
+func (w *Writer) WriteNull() {
+	if w.Proto >= 3 {
+		w.wr.WriteString("_\r\n")
+		return
+	}
+	w.wr.WriteString("$-1\r\n")
+}
+
+// WriteNullArray writes a null array in RESP2
+func (w *Writer) WriteNullArray() {
+	if w.Proto >= 3 {
+		w.wr.WriteString("_\r\n")
+		return
+	}
+	w.wr.WriteString("*-1\r\n")
+}
+
+// WriteBool writes an integer 1 or 0 in RESP2
+func (w *Writer) WriteBool(b bool) {
+	if w.Proto >= 3 {
+		if b {
+			w.wr.WriteString("#t\r\n")
+		} else {
+			w.wr.WriteString("#f\r\n")
+		}
+		return
+	}
+	if b {
+		w.WriteInt(1)
+	} else {
+		w.WriteInt(0)
+	}
+}
+
+func (w *Writer) WriteArrayLen(n int) {
+	w.writeHeader(Array, int64(n))
+}
+
+// WriteMapLen writes the header of n key value pairs, an array of 2n elements in RESP2
+func (w *Writer) WriteMapLen(n int) {
+	if w.Proto >= 3 {
+		w.writeHeader(Map, int64(n))
+		return
+	}
+	w.writeHeader(Array, int64(2*n))
+}
+
+// WriteSetLen writes an array header in RESP2
+func (w *Writer) WriteSetLen(n int) {
+	if w.Proto >= 3 {
+		w.writeHeader(Set, int64(n))
+		return
+	}
+	w.writeHeader(Array, int64(n))
+}
+
+// WritePushLen writes an array header in RESP2
+func (w *Writer) WritePushLen(n int) {
+	if w.Proto >= 3 {
+		w.writeHeader(Push, int64(n))
+		return
+	}
+	w.writeHeader(Array, int64(n))
+}
+
+// WriteCommand writes a request as an array of bulk strings
+func (w *Writer) WriteCommand(args ...string) {
+	w.WriteArrayLen(len(args))
+	for _, arg := range args {
+		w.WriteBulkString(arg)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// commands allowed before the connection is authenticated
+var noAuthCommands = map[string]bool{
+	"auth":  true,
+	"hello": true,
+	"quit":  true,
+}
+
+// dangerous commands of the server, like the @dangerous category of redis
+var dangerousCommands = map[string]bool{
+	"monitor": true,
+	"slowlog": true,
+	"info":    true,
+	"acl":     true,
+}
+
+// checkACL checks the command of the server and its channels with the user
+// of the connection, the commands of cache are checked by cache
+func (c *conn) checkACL(name string, cmd command, args [][]byte) error {
+	if noAuthCommands[name] || (name == "acl" && strings.EqualFold(string(args[1]), "whoami")) {
+		return nil
+	}
+	err := c.srv.cache.ACLCheck(c.user, cmd.info(name))
+	if err != nil {
+		return err
+	}
+	switch name {
+	case "subscribe", "psubscribe":
+		for _, channel := range args[1:] {
+			err = c.srv.cache.ACLCheckChannel(c.user, string(channel), name == "psubscribe")
+			if err != nil {
+				return err
+			}
+		}
+	case "publish":
+		return c.srv.cache.ACLCheckChannel(c.user, string(args[1]), false)
+	}
+	return nil
+}
+
+// auth authenticates the connection as user
+func (c *conn) auth(user, password string) error {
+	err := c.srv.cache.ACLAuth(user, password).Err()
+	if err != nil {
+		return err
+	}
+	c.user = user
+	c.authed = true
+	return nil
+}
+
+// AUTH [username] password
+func cmdAuth(c *conn, args [][]byte) {
+	user, password := cache.DefaultUser, string(args[1])
+	if len(args) == 3 {
+		user, password = string(args[1]), string(args[2])
+	}
+	if err := c.auth(user, password); err != nil {
+		c.writeErr(err)
+		return
+	}
+	c.wr.WriteSimple("OK")
+}
+
+// ACL SETUSER username [rule ...] | GETUSER username | DELUSER username [username ...]
+// | LIST | USERS | WHOAMI
+func cmdACL(c *conn, args [][]byte) {
+	switch sub := string(args[1]); {
+	case strings.EqualFold(sub, "setuser") && len(args) >= 3:
+		c.writeReply(c.srv.cache.ACLSetUser(string(args[2]), toStrings(args[3:])...).Result())
+	case strings.EqualFold(sub, "getuser") && len(args) == 3:
+		info, err := c.srv.cache.ACLGetUser(string(args[2])).Result()
+		if err != nil {
+			c.writeReply(nil, err)
+			return
+		}
+		flags := []string{"off"}
+		if info.Enabled {
+			flags[0] = "on"
+		}
+		if info.NoPass {
+			flags = append(flags, "nopass")
+		}
+		c.wr.WriteMapLen(5)
+		c.wr.WriteBulkString("flags")
+		c.writeReply(flags, nil)
+		c.wr.WriteBulkString("passwords")
+		c.writeReply(info.Passwords, nil)
+		c.wr.WriteBulkString("commands")
+		c.wr.WriteBulkString(info.Commands)
+		c.wr.WriteBulkString("keys")
+		c.wr.WriteBulkString(prefixJoin("~", info.Keys))
+		c.wr.WriteBulkString("channels")
+		c.wr.WriteBulkString(prefixJoin("&", info.Channels))
+	case strings.EqualFold(sub, "deluser") && len(args) >= 3:
+		c.writeReply(c.srv.cache.ACLDelUser(toStrings(args[2:])...).Result())
+	case strings.EqualFold(sub, "list") && len(args) == 2:
+		c.writeReply(c.srv.cache.ACLList().Result())
+	case strings.EqualFold(sub, "users") && len(args) == 2:
+		c.writeReply(c.srv.cache.ACLUsers().Result())
+	case strings.EqualFold(sub, "whoami") && len(args) == 2:
+		c.wr.WriteBulkString(c.user)
+	default:
+		c.wr.WriteError("ERR unknown subcommand or wrong number of arguments for '" + sub + "'")
+	}
+}
+
+func prefixJoin(prefix string, names []string) string {
+	prefixed := make([]string, len(names))
+	for i, name := range names {
+		prefixed[i] = prefix + name
+	}
+	return strings.Join(prefixed, " ")
+}
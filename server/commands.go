@@ -0,0 +1,344 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// command of the connection and the server, commands of the data are
+// dispatched to the command table of cache
+type command struct {
+	// arity like redis, including the command name, negative means at least -arity
+	arity   int
+	flags   cache.CommandFlag
+	group   string
+	summary string
+	handler func(c *conn, args [][]byte)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// connection
+		"ping":   {-1, cache.FlagFast, "connection", "Ping the server", cmdPing},
+		"echo":   {2, cache.FlagFast, "connection", "Echo the given string", cmdEcho},
+		"quit":   {1, cache.FlagFast, "connection", "Close the connection", cmdQuit},
+		"hello":  {-1, cache.FlagFast, "connection", "Handshake with the server and switch the protocol", cmdHello},
+		"select": {2, cache.FlagFast, "connection", "Change the selected database", cmdSelect},
+		"client": {-2, 0, "connection", "Get or set the client id and name", cmdClient},
+		"auth":   {-2, cache.FlagFast, "connection", "Authenticate to the server", cmdAuth},
+		// server
+		"command": {-1, 0, "server", "Get the details of the commands", cmdCommand},
+		"info":    {-1, 0, "server", "Get information and statistics about the server", cmdInfo},
+		"slowlog": {-2, 0, "server", "Get, count or reset the slow log", cmdSlowLog},
+		"monitor": {1, 0, "server", "Listen for all commands received by the server in real time", cmdMonitor},
+		"acl":     {-2, 0, "server", "Manage the users, AUTH checks their passwords", cmdACL},
+		// pub/sub
+		"subscribe":    {-2, 0, "pubsub", "Listen for messages published to channels", cmdSubscribe},
+		"psubscribe":   {-2, 0, "pubsub", "Listen for messages published to channels matching patterns", cmdPSubscribe},
+		"unsubscribe":  {-1, 0, "pubsub", "Stop listening for messages posted to channels", cmdUnsubscribe},
+		"punsubscribe": {-1, 0, "pubsub", "Stop listening for messages posted to channels matching patterns", cmdPUnsubscribe},
+		"publish":      {3, cache.FlagFast, "pubsub", "Post a message to a channel", cmdPublish},
+		"pubsub":       {-2, 0, "pubsub", "Inspect the state of the pub/sub subsystem", cmdPubSub},
+	}
+}
+
+// commandInfos returns the commands of the server and of cache sorted by name
+func (srv *Server) commandInfos() []cache.CommandInfo {
+	infos := srv.cache.Commands()
+	for name, cmd := range commands {
+		infos = append(infos, cmd.info(name))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func (srv *Server) lookupCommandInfo(name string) (cache.CommandInfo, bool) {
+	name = strings.ToLower(name)
+	if cmd, ok := commands[name]; ok {
+		return cmd.info(name), true
+	}
+	return srv.cache.LookupCommand(name)
+}
+
+func (cmd command) info(name string) cache.CommandInfo {
+	info := cache.CommandInfo{Name: name, Arity: cmd.arity, Flags: cmd.flags, Group: cmd.group, Summary: cmd.summary}
+	if dangerousCommands[name] {
+		info.Categories = []string{"dangerous"}
+	}
+	return info
+}
+
+func toStrings(args [][]byte) []string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = string(arg)
+	}
+	return strs
+}
+
+// parseInt writes the redis error when arg is not an integer
+func (c *conn) parseInt(arg []byte) (int, bool) {
+	n, err := strconv.Atoi(string(arg))
+	if err != nil {
+		c.wr.WriteError("ERR value is not an integer or out of range")
+		return 0, false
+	}
+	return n, true
+}
+
+func cmdPing(c *conn, args [][]byte) {
+	switch len(args) {
+	case 1:
+		c.wr.WriteSimple("PONG")
+	case 2:
+		c.wr.WriteBulk(args[1])
+	default:
+		c.wr.WriteError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(c *conn, args [][]byte) {
+	c.wr.WriteBulk(args[1])
+}
+
+func cmdQuit(c *conn, args [][]byte) {
+	c.wr.WriteSimple("OK")
+	c.quit = true
+}
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func cmdHello(c *conn, args [][]byte) {
+	proto := c.wr.Proto
+	if len(args) > 1 {
+		ver, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			c.wr.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if ver != 2 && ver != 3 {
+			c.wr.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = ver
+	}
+	name := c.name
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "auth":
+			if i+2 >= len(args) {
+				c.wr.WriteError("ERR syntax error in HELLO option 'auth'")
+				return
+			}
+			if err := c.auth(string(args[i+1]), string(args[i+2])); err != nil {
+				c.writeErr(err)
+				return
+			}
+			i += 2
+		case "setname":
+			if i+1 >= len(args) {
+				c.wr.WriteError("ERR syntax error in HELLO option 'setname'")
+				return
+			}
+			name = string(args[i+1])
+			i++
+		default:
+			c.wr.WriteError("ERR syntax error in HELLO option '" + string(args[i]) + "'")
+			return
+		}
+	}
+	c.wr.Proto = proto
+	c.name = name
+	c.wr.WriteMapLen(7)
+	c.wr.WriteBulkString("server")
+	c.wr.WriteBulkString("mem-cache")
+	c.wr.WriteBulkString("version")
+	c.wr.WriteBulkString(Version)
+	c.wr.WriteBulkString("proto")
+	c.wr.WriteInt(int64(proto))
+	c.wr.WriteBulkString("id")
+	c.wr.WriteInt(c.id)
+	c.wr.WriteBulkString("mode")
+	c.wr.WriteBulkString("standalone")
+	c.wr.WriteBulkString("role")
+	c.wr.WriteBulkString("master")
+	c.wr.WriteBulkString("modules")
+	c.wr.WriteArrayLen(0)
+}
+
+// COMMAND [COUNT|INFO [name ...]|DOCS [name ...]], every entry of COMMAND
+// and COMMAND INFO is name, arity, flags, first key, last key and step
+func cmdCommand(c *conn, args [][]byte) {
+	if len(args) == 1 {
+		infos := c.srv.commandInfos()
+		c.wr.WriteArrayLen(len(infos))
+		for _, info := range infos {
+			c.writeCommandInfo(info)
+		}
+		return
+	}
+	switch sub := strings.ToLower(string(args[1])); {
+	case sub == "count" && len(args) == 2:
+		c.wr.WriteInt(int64(len(c.srv.commandInfos())))
+	case sub == "info":
+		infos := c.srv.commandInfos()
+		if len(args) > 2 {
+			c.wr.WriteArrayLen(len(args) - 2)
+			for _, name := range args[2:] {
+				info, ok := c.srv.lookupCommandInfo(string(name))
+				if !ok {
+					c.wr.WriteNullArray()
+					continue
+				}
+				c.writeCommandInfo(info)
+			}
+			return
+		}
+		c.wr.WriteArrayLen(len(infos))
+		for _, info := range infos {
+			c.writeCommandInfo(info)
+		}
+	case sub == "docs":
+		var infos []cache.CommandInfo
+		if len(args) > 2 {
+			for _, name := range args[2:] {
+				if info, ok := c.srv.lookupCommandInfo(string(name)); ok {
+					infos = append(infos, info)
+				}
+			}
+		} else {
+			infos = c.srv.commandInfos()
+		}
+		c.wr.WriteMapLen(len(infos))
+		for _, info := range infos {
+			c.wr.WriteBulkString(info.Name)
+			c.wr.WriteMapLen(2)
+			c.wr.WriteBulkString("summary")
+			c.wr.WriteBulkString(info.Summary)
+			c.wr.WriteBulkString("group")
+			c.wr.WriteBulkString(info.Group)
+		}
+	default:
+		c.wr.WriteError("ERR unknown subcommand or wrong number of arguments for '" + string(args[1]) + "'")
+	}
+}
+
+func (c *conn) writeCommandInfo(info cache.CommandInfo) {
+	c.wr.WriteArrayLen(6)
+	c.wr.WriteBulkString(info.Name)
+	c.wr.WriteInt(int64(info.Arity))
+	flags := info.Flags.Names()
+	c.wr.WriteSetLen(len(flags))
+	for _, flag := range flags {
+		c.wr.WriteSimple(flag)
+	}
+	c.wr.WriteInt(int64(info.FirstKey))
+	c.wr.WriteInt(int64(info.LastKey))
+	c.wr.WriteInt(int64(info.KeyStep))
+}
+
+func cmdSelect(c *conn, args [][]byte) {
+	index, ok := c.parseInt(args[1])
+	if !ok {
+		return
+	}
+	db, err := c.srv.cache.DB(index)
+	if err != nil {
+		c.wr.WriteError("ERR DB index is out of range")
+		return
+	}
+	c.db = db
+	c.wr.WriteSimple("OK")
+}
+
+// CLIENT ID|GETNAME|SETNAME name
+func cmdClient(c *conn, args [][]byte) {
+	sub := strings.ToLower(string(args[1]))
+	switch {
+	case sub == "id" && len(args) == 2:
+		c.wr.WriteInt(c.id)
+	case sub == "getname" && len(args) == 2:
+		if c.name == "" {
+			c.wr.WriteNull()
+			return
+		}
+		c.wr.WriteBulkString(c.name)
+	case sub == "setname" && len(args) == 3:
+		c.name = string(args[2])
+		c.wr.WriteSimple("OK")
+	default:
+		c.wr.WriteError("ERR unknown subcommand or wrong number of arguments for '" + string(args[1]) + "'")
+	}
+}
+
+// INFO [section ...], the clients section is added to the sections of cache
+func cmdInfo(c *conn, args [][]byte) {
+	sections := toStrings(args[1:])
+	info, err := c.db.Info(sections...).Result()
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+	clients := len(sections) == 0
+	for _, section := range sections {
+		switch strings.ToLower(section) {
+		case "clients", "all", "everything", "default":
+			clients = true
+		}
+	}
+	if clients {
+		c.srv.mu.Lock()
+		connected := len(c.srv.conns)
+		c.srv.mu.Unlock()
+		text := "# Clients\r\nconnected_clients:" + strconv.Itoa(connected) + "\r\n"
+		if info != "" {
+			text += "\r\n" + info
+		}
+		info = text
+	}
+	c.wr.WriteBulkString(info)
+}
+
+// SLOWLOG GET [count] | LEN | RESET, GET returns 10 entries by default and
+// every entry is id, timestamp, microseconds, arguments, address and name
+func cmdSlowLog(c *conn, args [][]byte) {
+	switch sub := string(args[1]); {
+	case strings.EqualFold(sub, "get") && len(args) <= 3:
+		count := 10
+		if len(args) == 3 {
+			var ok bool
+			if count, ok = c.parseInt(args[2]); !ok {
+				return
+			}
+		}
+		entries, err := c.srv.cache.SlowLogGet(count).Result()
+		if err != nil {
+			c.writeErr(err)
+			return
+		}
+		c.wr.WriteArrayLen(len(entries))
+		for _, entry := range entries {
+			c.wr.WriteArrayLen(6)
+			c.wr.WriteInt(entry.ID)
+			c.wr.WriteInt(entry.Time.Unix())
+			c.wr.WriteInt(int64(entry.Duration / time.Microsecond))
+			c.wr.WriteArrayLen(len(entry.Args))
+			for _, arg := range entry.Args {
+				c.wr.WriteBulkString(arg)
+			}
+			c.wr.WriteBulkString(entry.Client.Addr)
+			c.wr.WriteBulkString(entry.Client.Name)
+		}
+	case strings.EqualFold(sub, "len") && len(args) == 2:
+		c.writeReply(c.srv.cache.SlowLogLen().Result())
+	case strings.EqualFold(sub, "reset") && len(args) == 2:
+		c.writeReply(c.srv.cache.SlowLogReset().Result())
+	default:
+		c.wr.WriteError("ERR unknown subcommand or wrong number of arguments for '" + sub + "'")
+	}
+}
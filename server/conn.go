@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/wangyanga9/mem-cache/cache"
+	"github.com/wangyanga9/mem-cache/resp"
+)
+
+// conn is the state of a client connection
+type conn struct {
+	srv  *Server
+	nc   net.Conn
+	id   int64
+	name string
+	// acl user, authed is false until AUTH when the default user has a password
+	user   string
+	authed bool
+	// database selected by SELECT
+	db   *cache.MemCache
+	rd   *resp.Reader
+	wr   *resp.Writer
+	quit bool
+	// wmu guards wr, messages of SUBSCRIBE are written by another goroutine
+	wmu sync.Mutex
+	// subscriptions, nil before the first SUBSCRIBE or PSUBSCRIBE
+	ps        *cache.PubSub
+	forwarded chan struct{}
+	// commands received in the MONITOR state, nil before MONITOR
+	monitor   *cache.Monitor
+	monitored chan struct{}
+}
+
+func newConn(srv *Server, nc net.Conn, id int64) *conn {
+	return &conn{
+		srv: srv,
+		nc:  nc,
+		id:  id,
+		db:  srv.cache,
+		rd:  resp.NewReader(nc),
+		wr:  resp.NewWriter(nc),
+
+		user:   cache.DefaultUser,
+		authed: srv.cache.ACLAuth(cache.DefaultUser, "").Err() == nil,
+	}
+}
+
+func (c *conn) serve() {
+	defer c.nc.Close()
+	if tc, ok := c.nc.(*tls.Conn); ok {
+		if err := c.handshake(tc); err != nil {
+			return
+		}
+	}
+	defer c.closePubSub()
+	defer c.closeMonitor()
+	for !c.quit {
+		args, err := c.rd.ReadCommand()
+		if err != nil {
+			if errors.Is(err, resp.ErrProtocol) {
+				c.wmu.Lock()
+				c.wr.WriteError("ERR " + err.Error())
+				c.wr.Flush()
+				c.wmu.Unlock()
+			}
+			return
+		}
+		c.wmu.Lock()
+		c.dispatch(args)
+		// flush once after the pipelined commands
+		if c.rd.Buffered() == 0 || c.quit {
+			err = c.wr.Flush()
+		}
+		c.wmu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *conn) dispatch(args [][]byte) {
+	name := strings.ToLower(string(args[0]))
+	if !c.authed && !noAuthCommands[name] {
+		c.wr.WriteError("NOAUTH Authentication required.")
+		return
+	}
+	if c.monitor != nil && name != "quit" {
+		c.wr.WriteError("ERR Can't execute '" + name + "': only QUIT is allowed in the MONITOR state")
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		if c.subscribed() {
+			c.wr.WriteError("ERR Can't execute '" + name + "': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+			return
+		}
+		if _, ok := c.srv.cache.LookupCommand(name); !ok {
+			c.wr.WriteError("ERR unknown command '" + string(args[0]) + "'")
+			return
+		}
+		c.doCache(name, args)
+		return
+	}
+	if c.subscribed() && !subscribedCommands[name] {
+		c.wr.WriteError("ERR Can't execute '" + name + "': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.wr.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	if err := c.checkACL(name, cmd, args); err != nil {
+		c.writeErr(err)
+		return
+	}
+	cmd.handler(c, args)
+}
+
+// doCache runs a command of the cache command table in the selected database
+func (c *conn) doCache(name string, args [][]byte) {
+	cmdArgs := make([]interface{}, len(args))
+	cmdArgs[0] = name
+	for i, arg := range args[1:] {
+		cmdArgs[i+1] = arg
+	}
+	val, err := c.db.Do(c.context(), cmdArgs...).Result()
+	c.writeReply(val, err)
+}
+
+// context carries the client of the connection for the slow log, the
+// monitors and the acl
+func (c *conn) context() context.Context {
+	return cache.WithClientInfo(context.Background(), cache.ClientInfo{
+		ID:   c.id,
+		Name: c.name,
+		Addr: c.nc.RemoteAddr().String(),
+		User: c.user,
+	})
+}
+
+// writeErr writes err with the redis error code
+func (c *conn) writeErr(err error) {
+	switch {
+	case errors.Is(err, cache.ErrWrongType), errors.Is(err, cache.ErrNoPermission), errors.Is(err, cache.ErrWrongPass):
+		// message already starts with WRONGTYPE, NOPERM or WRONGPASS
+		c.wr.WriteError(err.Error())
+	case errors.Is(err, cache.ErrLimitExceeded):
+		c.wr.WriteError("OOM " + err.Error())
+	default:
+		c.wr.WriteError("ERR " + err.Error())
+	}
+}
+
+// writeReply writes the value of a cache command, true is OK and Nil is null
+func (c *conn) writeReply(val interface{}, err error) {
+	if err == cache.Nil {
+		c.wr.WriteNull()
+		return
+	}
+	if err != nil {
+		c.writeErr(err)
+		return
+	}
+	switch val := val.(type) {
+	case nil:
+		c.wr.WriteNull()
+	case []byte:
+		c.wr.WriteBulk(val)
+	case string:
+		c.wr.WriteBulkString(val)
+	case int:
+		c.wr.WriteInt(int64(val))
+	case bool:
+		if val {
+			c.wr.WriteSimple("OK")
+		} else {
+			c.wr.WriteInt(0)
+		}
+	case []string:
+		c.wr.WriteArrayLen(len(val))
+		for _, s := range val {
+			c.wr.WriteBulkString(s)
+		}
+	case []interface{}:
+		c.wr.WriteArrayLen(len(val))
+		for _, v := range val {
+			c.writeReply(v, nil)
+		}
+	default:
+		c.wr.WriteError(fmt.Sprintf("ERR unsupported reply type %T", val))
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import "github.com/wangyanga9/mem-cache/cache"
+
+// MONITOR streams the data commands of all connections and of the other
+// users of the cache, only QUIT is allowed afterwards
+func cmdMonitor(c *conn, args [][]byte) {
+	c.monitor = c.srv.cache.Monitor()
+	c.monitored = make(chan struct{})
+	c.wr.WriteSimple("OK")
+	go c.forwardMonitor(c.monitor)
+}
+
+// forwardMonitor writes the events until the monitor is closed, events are
+// dropped by the cache when the connection can't keep up
+func (c *conn) forwardMonitor(m *cache.Monitor) {
+	defer close(c.monitored)
+	for ev := range m.Channel() {
+		c.wmu.Lock()
+		c.wr.WriteSimple(ev.String())
+		var err error
+		if len(m.Channel()) == 0 {
+			err = c.wr.Flush()
+		}
+		c.wmu.Unlock()
+		if err != nil {
+			c.nc.Close()
+			return
+		}
+	}
+}
+
+func (c *conn) closeMonitor() {
+	if c.monitor == nil {
+		return
+	}
+	c.monitor.Close()
+	<-c.monitored
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// commands allowed in the subscribed state of RESP2
+var subscribedCommands = map[string]bool{
+	"subscribe":    true,
+	"psubscribe":   true,
+	"unsubscribe":  true,
+	"punsubscribe": true,
+	"ping":         true,
+	"quit":         true,
+}
+
+// subscribed reports whether the connection only receives messages,
+// RESP3 connections can run any command while subscribed
+func (c *conn) subscribed() bool {
+	return c.wr.Proto == 2 && c.ps != nil && c.ps.Subscriptions() > 0
+}
+
+// pubSub returns the subscriptions of the connection, the goroutine
+// writing the messages is started on the first call
+func (c *conn) pubSub() *cache.PubSub {
+	if c.ps == nil {
+		c.ps = c.srv.cache.Subscribe()
+		c.forwarded = make(chan struct{})
+		go c.forward(c.ps)
+	}
+	return c.ps
+}
+
+// forward writes the messages until the subscriptions are closed, a slow
+// subscriber is disconnected like redis when the policy is disconnect
+func (c *conn) forward(ps *cache.PubSub) {
+	defer close(c.forwarded)
+	for msg := range ps.Channel() {
+		c.wmu.Lock()
+		if msg.Pattern != "" {
+			c.writePushLen(4)
+			c.wr.WriteBulkString("pmessage")
+			c.wr.WriteBulkString(msg.Pattern)
+		} else {
+			c.writePushLen(3)
+			c.wr.WriteBulkString("message")
+		}
+		c.wr.WriteBulkString(msg.Channel)
+		c.wr.WriteBulk(msg.Payload)
+		err := c.wr.Flush()
+		c.wmu.Unlock()
+		if err != nil {
+			c.nc.Close()
+			return
+		}
+	}
+	if ps.Err() == cache.ErrSlowSubscriber {
+		c.nc.Close()
+	}
+}
+
+func (c *conn) closePubSub() {
+	if c.ps == nil {
+		return
+	}
+	c.ps.Close()
+	<-c.forwarded
+}
+
+// writePushLen writes a push in RESP3 and an array in RESP2
+func (c *conn) writePushLen(n int) {
+	if c.wr.Proto == 3 {
+		c.wr.WritePushLen(n)
+		return
+	}
+	c.wr.WriteArrayLen(n)
+}
+
+// writeSubscription writes the reply of every channel of (P)(UN)SUBSCRIBE
+func (c *conn) writeSubscription(kind string, name interface{}) {
+	c.writePushLen(3)
+	c.wr.WriteBulkString(kind)
+	if s, ok := name.(string); ok {
+		c.wr.WriteBulkString(s)
+	} else {
+		c.wr.WriteNull()
+	}
+	c.wr.WriteInt(int64(c.ps.Subscriptions()))
+}
+
+func cmdSubscribe(c *conn, args [][]byte) {
+	ps := c.pubSub()
+	for _, channel := range toStrings(args[1:]) {
+		if err := ps.Subscribe(channel); err != nil {
+			c.writeErr(err)
+			return
+		}
+		c.writeSubscription("subscribe", channel)
+	}
+}
+
+func cmdPSubscribe(c *conn, args [][]byte) {
+	ps := c.pubSub()
+	for _, pattern := range toStrings(args[1:]) {
+		if err := ps.PSubscribe(pattern); err != nil {
+			c.writeErr(err)
+			return
+		}
+		c.writeSubscription("psubscribe", pattern)
+	}
+}
+
+func cmdUnsubscribe(c *conn, args [][]byte) {
+	ps := c.pubSub()
+	channels := toStrings(args[1:])
+	if len(channels) == 0 {
+		channels = ps.Channels()
+	}
+	if len(channels) == 0 {
+		c.writeSubscription("unsubscribe", nil)
+		return
+	}
+	for _, channel := range channels {
+		ps.Unsubscribe(channel)
+		c.writeSubscription("unsubscribe", channel)
+	}
+}
+
+func cmdPUnsubscribe(c *conn, args [][]byte) {
+	ps := c.pubSub()
+	patterns := toStrings(args[1:])
+	if len(patterns) == 0 {
+		patterns = ps.Patterns()
+	}
+	if len(patterns) == 0 {
+		c.writeSubscription("punsubscribe", nil)
+		return
+	}
+	for _, pattern := range patterns {
+		ps.PUnsubscribe(pattern)
+		c.writeSubscription("punsubscribe", pattern)
+	}
+}
+
+func cmdPublish(c *conn, args [][]byte) {
+	n, err := c.srv.cache.Publish(string(args[1]), args[2]).Result()
+	c.writeReply(n, err)
+}
+
+// PUBSUB CHANNELS [pattern]|NUMSUB [channel ...]|NUMPAT
+func cmdPubSub(c *conn, args [][]byte) {
+	switch sub := string(args[1]); {
+	case strings.EqualFold(sub, "channels") && len(args) <= 3:
+		pattern := ""
+		if len(args) == 3 {
+			pattern = string(args[2])
+		}
+		channels, err := c.srv.cache.PubSubChannels(pattern).Result()
+		c.writeReply(channels, err)
+	case strings.EqualFold(sub, "numsub"):
+		channels := toStrings(args[2:])
+		numSub, err := c.srv.cache.PubSubNumSub(channels...).Result()
+		if err != nil {
+			c.writeErr(err)
+			return
+		}
+		c.wr.WriteArrayLen(len(channels) * 2)
+		for _, channel := range channels {
+			c.wr.WriteBulkString(channel)
+			c.wr.WriteInt(int64(numSub[channel]))
+		}
+	case strings.EqualFold(sub, "numpat") && len(args) == 2:
+		c.writeReply(c.srv.cache.PubSubNumPat().Result())
+	default:
+		c.wr.WriteError("ERR unknown subcommand or wrong number of arguments for '" + sub + "'")
+	}
+}
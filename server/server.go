@@ -0,0 +1,121 @@
+// Package server exposes a MemCache over TCP with the redis protocol,
+// so redis-cli and redis clients can talk to it
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/wangyanga9/mem-cache/cache"
+)
+
+// Version is reported by HELLO
+const Version = "0.1.0"
+
+var ErrServerClosed = errors.New("server: Server closed")
+
+type Server struct {
+	cache *cache.MemCache
+
+	// TLSClientCertUser authenticates a TLS connection with a verified client
+	// certificate as the acl user named by the CN of the certificate, set it
+	// before Serve
+	TLSClientCertUser bool
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	nextID    int64
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// New returns a server of c, every connection starts at database 0 of c
+func New(c *cache.MemCache) *Server {
+	db0, _ := c.DB(0)
+	return &Server{
+		cache:     db0,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve
+func (srv *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
+// Serve accepts connections on ln until Close is called, it always
+// returns a non-nil error, ErrServerClosed after Close
+func (srv *Server) Serve(ln net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.listeners[ln] = struct{}{}
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.listeners, ln)
+		srv.mu.Unlock()
+		ln.Close()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		srv.mu.Lock()
+		if srv.closed {
+			srv.mu.Unlock()
+			nc.Close()
+			return ErrServerClosed
+		}
+		srv.nextID++
+		c := newConn(srv, nc, srv.nextID)
+		srv.conns[c] = struct{}{}
+		srv.wg.Add(1)
+		srv.mu.Unlock()
+		go func() {
+			defer srv.wg.Done()
+			c.serve()
+			srv.mu.Lock()
+			delete(srv.conns, c)
+			srv.mu.Unlock()
+		}()
+	}
+}
+
+// Close closes all listeners and connections, and waits for the
+// connection goroutines to return, the MemCache is not closed
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return ErrServerClosed
+	}
+	srv.closed = true
+	for ln := range srv.listeners {
+		ln.Close()
+	}
+	for c := range srv.conns {
+		c.nc.Close()
+	}
+	srv.mu.Unlock()
+	srv.wg.Wait()
+	return nil
+}
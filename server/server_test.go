@@ -0,0 +1,598 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wangyanga9/mem-cache/cache"
+	"github.com/wangyanga9/mem-cache/resp"
+)
+
+type client struct {
+	nc net.Conn
+	rd *resp.Reader
+	wr *resp.Writer
+}
+
+func newTestServer(t *testing.T) (*client, func()) {
+	return serveCache(t, newTestCache(t))
+}
+
+func newTestCache(t *testing.T) *cache.MemCache {
+	c, err := cache.NewMemCache(&cache.CacheConf{MaxSize: 10, Databases: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return c
+}
+
+// serveCache serves c and returns a connected client, the returned func
+// closes the server and c
+func serveCache(t *testing.T, c *cache.MemCache) (*client, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	srv := New(c)
+	go srv.Serve(ln)
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cli := &client{nc: nc, rd: resp.NewReader(nc), wr: resp.NewWriter(nc)}
+	return cli, func() {
+		srv.Close()
+		c.Close()
+	}
+}
+
+func (cli *client) do(t *testing.T, args ...string) resp.Value {
+	cli.wr.WriteCommand(args...)
+	if err := cli.wr.Flush(); err != nil {
+		t.Fatal(err.Error())
+	}
+	v, err := cli.rd.ReadValue()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return v
+}
+
+func TestPing(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	v := cli.do(t, "PING")
+	if v.Type != resp.SimpleString || v.String() != "PONG" {
+		t.Fatal("ping result error")
+	}
+	// inline command
+	cli.nc.Write([]byte("ping hello\r\n"))
+	v, err := cli.rd.ReadValue()
+	if err != nil || v.Type != resp.BulkString || v.String() != "hello" {
+		t.Fatal("inline ping result error")
+	}
+}
+
+func TestStringCommands(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	v := cli.do(t, "set", "key1", "value1")
+	if v.Type != resp.SimpleString || v.String() != "OK" {
+		t.Fatal("set result error")
+	}
+	v = cli.do(t, "get", "key1")
+	if v.Type != resp.BulkString || v.String() != "value1" {
+		t.Fatal("get result error")
+	}
+	v = cli.do(t, "get", "notexist")
+	if !v.IsNull {
+		t.Fatal("get not exist key should be null")
+	}
+	v = cli.do(t, "hget", "key1", "field1")
+	if v.Type != resp.Error || v.String()[:9] != "WRONGTYPE" {
+		t.Fatal("hget string key should be WRONGTYPE")
+	}
+	v = cli.do(t, "del", "key1", "notexist")
+	if v.Type != resp.Integer || v.Int != 1 {
+		t.Fatal("del result error")
+	}
+	v = cli.do(t, "get")
+	if v.Type != resp.Error {
+		t.Fatal("wrong arity should be error")
+	}
+	v = cli.do(t, "notexist")
+	if v.Type != resp.Error {
+		t.Fatal("unknown command should be error")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	cli.do(t, "sadd", "key1", "a", "b")
+	v := cli.do(t, "select", "1")
+	if v.Type != resp.SimpleString {
+		t.Fatal("select result error")
+	}
+	v = cli.do(t, "sismember", "key1", "a")
+	if v.Int != 0 {
+		t.Fatal("key1 should not exist in db 1")
+	}
+	v = cli.do(t, "select", "2")
+	if v.Type != resp.Error {
+		t.Fatal("select out of range should be error")
+	}
+	cli.do(t, "swapdb", "0", "1")
+	v = cli.do(t, "sismember", "key1", "a")
+	if v.Int != 1 {
+		t.Fatal("key1 should exist in db 1 after swapdb")
+	}
+}
+
+func TestHello3(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	v := cli.do(t, "hello", "3", "setname", "test")
+	if v.Type != resp.Map || len(v.Elems) != 14 {
+		t.Fatal("hello 3 should reply a map")
+	}
+	v = cli.do(t, "get", "notexist")
+	if v.Type != resp.Null {
+		t.Fatal("null should be RESP3 null")
+	}
+	v = cli.do(t, "client", "getname")
+	if v.String() != "test" {
+		t.Fatal("client name error")
+	}
+	v = cli.do(t, "hello", "4")
+	if v.Type != resp.Error {
+		t.Fatal("hello 4 should be error")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	for i := 0; i < 3; i++ {
+		cli.wr.WriteCommand("hset", "key1", "field1", "1")
+	}
+	cli.wr.Flush()
+	for i, want := range []int64{1, 0, 0} {
+		v, err := cli.rd.ReadValue()
+		if err != nil || v.Int != want {
+			t.Fatal("pipeline result error, i=", i)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	v := cli.do(t, "command", "count")
+	count := v.Int
+	if v.Type != resp.Integer || count < 20 {
+		t.Fatal("command count error")
+	}
+	v = cli.do(t, "command")
+	if len(v.Elems) != int(count) {
+		t.Fatal("command should list every command")
+	}
+	v = cli.do(t, "command", "info", "GET", "notexist", "ping")
+	if len(v.Elems) != 3 || !v.Elems[1].IsNull {
+		t.Fatal("command info result error")
+	}
+	get := v.Elems[0].Elems
+	if get[0].String() != "get" || get[1].Int != 2 || get[3].Int != 1 || get[4].Int != 1 || get[5].Int != 1 {
+		t.Fatal("command info get error")
+	}
+	if len(get[2].Elems) != 2 || get[2].Elems[0].String() != "readonly" || get[2].Elems[1].String() != "fast" {
+		t.Fatal("command info get flags error")
+	}
+	v = cli.do(t, "command", "info", "mcstore")
+	if !v.Elems[0].IsNull {
+		t.Fatal("internal command should not be listed")
+	}
+	v = cli.do(t, "command", "docs", "set")
+	if len(v.Elems) != 2 || v.Elems[0].String() != "set" || v.Elems[1].Elems[1].String() == "" {
+		t.Fatal("command docs result error")
+	}
+}
+
+func TestInfo(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	cli.do(t, "set", "k", "v")
+	info := cli.do(t, "info").String()
+	if !strings.HasPrefix(info, "# Clients\r\nconnected_clients:1\r\n") || !strings.Contains(info, "db0:keys=1,") {
+		t.Fatal("info result error")
+	}
+	info = cli.do(t, "info", "keyspace").String()
+	if !strings.HasPrefix(info, "# Keyspace\r\n") {
+		t.Fatal("info keyspace result error")
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	c := newTestCache(t)
+	err := c.RegisterCommand("sleep", cache.CommandSpec{Arity: 1, Handler: func(tx *cache.Tx, result cache.IResult) {
+		time.Sleep(11 * time.Millisecond)
+		result.SetVal("OK")
+	}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cli, closeFunc := serveCache(t, c)
+	defer closeFunc()
+	cli.do(t, "client", "setname", "worker")
+	cli.do(t, "set", "k", "v")
+	cli.do(t, "sleep")
+	if v := cli.do(t, "slowlog", "len"); v.Int != 1 {
+		t.Fatal("slowlog len error")
+	}
+	v := cli.do(t, "slowlog", "get")
+	if len(v.Elems) != 1 || len(v.Elems[0].Elems) != 6 {
+		t.Fatal("slowlog get result error")
+	}
+	entry := v.Elems[0].Elems
+	if entry[2].Int < 11000 || entry[3].Elems[0].String() != "sleep" || entry[4].String() == "" || entry[5].String() != "worker" {
+		t.Fatal("slowlog entry error")
+	}
+	cli.do(t, "slowlog", "reset")
+	if v := cli.do(t, "slowlog", "len"); v.Int != 0 {
+		t.Fatal("slowlog reset error")
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	mon := dial(t, cli.nc.RemoteAddr().String())
+	defer mon.nc.Close()
+	if v := mon.do(t, "monitor"); v.String() != "OK" {
+		t.Fatal("monitor result error")
+	}
+	cli.do(t, "select", "1")
+	cli.do(t, "set", "k", "v")
+	v, err := mon.rd.ReadValue()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(v.String(), " [1 127.0.0.1:") || !strings.HasSuffix(v.String(), `] "set" "k" "v"`) {
+		t.Fatal("monitor event error", v.String())
+	}
+	if v := mon.do(t, "get", "k"); v.Type != resp.Error {
+		t.Fatal("only quit is allowed after monitor")
+	}
+	if v := mon.do(t, "quit"); v.String() != "OK" {
+		t.Fatal("quit result error")
+	}
+}
+
+func TestACL(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	if v := cli.do(t, "acl", "whoami"); v.String() != "default" {
+		t.Fatal("acl whoami error")
+	}
+	v := cli.do(t, "acl", "setuser", "alice", "on", ">secret", "~app:*", "&news", "+@read", "+publish")
+	if v.String() != "OK" {
+		t.Fatal("acl setuser error", v.String())
+	}
+	cli.do(t, "set", "app:1", "v")
+	v = cli.do(t, "acl", "getuser", "alice")
+	if len(v.Elems) != 10 || v.Elems[5].String() != "-@all +@read +publish" || v.Elems[7].String() != "~app:*" {
+		t.Fatal("acl getuser error")
+	}
+	if v := cli.do(t, "acl", "users"); len(v.Elems) != 2 {
+		t.Fatal("acl users error")
+	}
+	if v := cli.do(t, "auth", "alice", "wrong"); v.Type != resp.Error || !strings.HasPrefix(v.String(), "WRONGPASS") {
+		t.Fatal("auth with wrong password should fail")
+	}
+	if v := cli.do(t, "auth", "alice", "secret"); v.String() != "OK" {
+		t.Fatal("auth error")
+	}
+	if v := cli.do(t, "get", "app:1"); v.String() != "v" {
+		t.Fatal("alice should read app keys")
+	}
+	if v := cli.do(t, "get", "other"); v.String() != "NOPERM No permissions to access a key" {
+		t.Fatal("alice should not read other keys", v.String())
+	}
+	if v := cli.do(t, "set", "app:1", "v"); v.String() != "NOPERM User alice has no permissions to run the 'set' command" {
+		t.Fatal("alice should not run set", v.String())
+	}
+	if v := cli.do(t, "publish", "news", "hi"); v.Type != resp.Integer {
+		t.Fatal("alice should publish to news")
+	}
+	if v := cli.do(t, "publish", "other", "hi"); v.String() != "NOPERM No permissions to access a channel" {
+		t.Fatal("alice should not publish to other channels")
+	}
+	if v := cli.do(t, "acl", "list"); v.Type != resp.Error {
+		t.Fatal("acl is dangerous")
+	}
+	if v := cli.do(t, "acl", "whoami"); v.String() != "alice" {
+		t.Fatal("acl whoami should be allowed")
+	}
+
+	admin := dial(t, cli.nc.RemoteAddr().String())
+	defer admin.nc.Close()
+	admin.do(t, "acl", "setuser", "default", "resetpass", ">adminpw")
+	other := dial(t, cli.nc.RemoteAddr().String())
+	defer other.nc.Close()
+	if v := other.do(t, "get", "app:1"); v.String() != "NOAUTH Authentication required." {
+		t.Fatal("default user should need a password", v.String())
+	}
+	if v := other.do(t, "hello", "3", "auth", "default", "adminpw"); v.Type != resp.Map {
+		t.Fatal("hello auth error")
+	}
+	if v := other.do(t, "get", "app:1"); v.String() != "v" {
+		t.Fatal("get after auth error")
+	}
+}
+
+func TestRegisteredCommand(t *testing.T) {
+	c := newTestCache(t)
+	err := c.RegisterCommand("getdefault", cache.CommandSpec{
+		Arity:    3,
+		Flags:    cache.FlagReadOnly,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+		KeyType:  cache.STRING,
+		Handler: func(tx *cache.Tx, result cache.IResult) {
+			val, err := tx.Do("get", result.Args()[0]).Result()
+			if err == cache.Nil {
+				val = result.Args()[1]
+			}
+			result.SetVal(val)
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cli, closeFunc := serveCache(t, c)
+	defer closeFunc()
+	v := cli.do(t, "getdefault", "key1", "default")
+	if v.String() != "default" {
+		t.Fatal("registered command result error")
+	}
+	v = cli.do(t, "command", "info", "getdefault")
+	if v.Elems[0].IsNull || v.Elems[0].Elems[1].Int != 3 {
+		t.Fatal("registered command should be listed")
+	}
+	v = cli.do(t, "expire", "key1", "abc")
+	if v.Type != resp.Error || v.String() != "ERR invalid argument: expire argument 2 value is not an integer or out of range" {
+		t.Fatal("expire error result error, v=", v.String())
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	cli, closeFunc := newTestServer(t)
+	defer closeFunc()
+	addr := cli.nc.RemoteAddr().String()
+	cases := []struct {
+		request, reply string
+	}{
+		{strings.Repeat("*1\r\n", 100000), "ERR Protocol error: too deep nesting"},
+		{"set \"a b\r\n", "ERR Protocol error: unbalanced quotes in request"},
+	}
+	for _, c := range cases {
+		cli := dial(t, addr)
+		cli.nc.Write([]byte(c.request))
+		v, err := cli.rd.ReadValue()
+		if err != nil || v.Type != resp.Error || v.String() != c.reply {
+			t.Fatal("protocol error reply error, v=", v.String())
+		}
+		cli.nc.Close()
+	}
+}
+
+func dial(t *testing.T, addr string) *client {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &client{nc: nc, rd: resp.NewReader(nc), wr: resp.NewWriter(nc)}
+}
+
+func TestPubSub(t *testing.T) {
+	sub, closeFunc := newTestServer(t)
+	defer closeFunc()
+	pub := dial(t, sub.nc.RemoteAddr().String())
+	v := sub.do(t, "subscribe", "ch1")
+	if len(v.Elems) != 3 || v.Elems[0].String() != "subscribe" || v.Elems[2].Int != 1 {
+		t.Fatal("subscribe result error")
+	}
+	v = sub.do(t, "psubscribe", "ch*")
+	if v.Elems[0].String() != "psubscribe" || v.Elems[2].Int != 2 {
+		t.Fatal("psubscribe result error")
+	}
+	v = sub.do(t, "get", "key1")
+	if v.Type != resp.Error {
+		t.Fatal("get in subscribed state should be error")
+	}
+	v = pub.do(t, "publish", "ch1", "hello")
+	if v.Int != 2 {
+		t.Fatal("publish result error")
+	}
+	v, err := sub.rd.ReadValue()
+	if err != nil || v.Elems[0].String() != "message" || v.Elems[1].String() != "ch1" || v.Elems[2].String() != "hello" {
+		t.Fatal("message error")
+	}
+	v, err = sub.rd.ReadValue()
+	if err != nil || v.Elems[0].String() != "pmessage" || v.Elems[1].String() != "ch*" || v.Elems[3].String() != "hello" {
+		t.Fatal("pmessage error")
+	}
+	v = pub.do(t, "pubsub", "numsub", "ch1", "ch2")
+	if len(v.Elems) != 4 || v.Elems[1].Int != 1 || v.Elems[3].Int != 0 {
+		t.Fatal("pubsub numsub error")
+	}
+	v = pub.do(t, "pubsub", "channels")
+	if len(v.Elems) != 1 || v.Elems[0].String() != "ch1" {
+		t.Fatal("pubsub channels error")
+	}
+	v = sub.do(t, "unsubscribe")
+	if v.Elems[1].String() != "ch1" || v.Elems[2].Int != 1 {
+		t.Fatal("unsubscribe result error")
+	}
+	v = sub.do(t, "punsubscribe")
+	if v.Elems[1].String() != "ch*" || v.Elems[2].Int != 0 {
+		t.Fatal("punsubscribe result error")
+	}
+	v = sub.do(t, "get", "key1")
+	if !v.IsNull {
+		t.Fatal("get after unsubscribe should work")
+	}
+}
+
+// writeCert creates a certificate signed by parent, or a self-signed CA when
+// parent is nil, and writes its PEM files to dir
+func writeCert(t *testing.T, dir, name string, tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return cert, key
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mem-cache-tls")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	ca, caKey := writeCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mem-cache"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeCert(t, dir, "alice", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "alice"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	conf := &TLSConf{
+		CertFile:   filepath.Join(dir, "server.crt"),
+		KeyFile:    filepath.Join(dir, "server.key"),
+		CACertFile: filepath.Join(dir, "ca.crt"),
+		MinVersion: "TLSv1.2",
+	}
+	config, err := conf.Config()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := (&TLSConf{CertFile: conf.CertFile, KeyFile: conf.KeyFile, MinVersion: "1.1"}).Config(); err == nil {
+		t.Fatal("tls 1.1 should not be supported")
+	}
+
+	c, err := cache.NewMemCache(&cache.CacheConf{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+	c.ACLSetUser(cache.DefaultUser, "resetpass", ">adminpw")
+	c.ACLSetUser("alice", "on", "~app:*", "+@all")
+	srv := New(c)
+	srv.TLSClientCertUser = true
+	defer srv.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tlsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	go srv.Serve(ln)
+	go srv.ServeTLS(tlsLn, config)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "alice.crt"), filepath.Join(dir, "alice.key"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	nc, err := tls.Dial("tcp", tlsLn.Addr().String(), &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cli := &client{nc: nc, rd: resp.NewReader(nc), wr: resp.NewWriter(nc)}
+	defer nc.Close()
+	if v := cli.do(t, "acl", "whoami"); v.String() != "alice" {
+		t.Fatal("client certificate should authenticate alice", v.String())
+	}
+	if v := cli.do(t, "set", "app:1", "v"); v.String() != "OK" {
+		t.Fatal("set over tls error", v.String())
+	}
+
+	// the plaintext port serves beside the tls port
+	plain := dial(t, ln.Addr().String())
+	defer plain.nc.Close()
+	if v := plain.do(t, "get", "app:1"); v.String() != "NOAUTH Authentication required." {
+		t.Fatal("plaintext connection should need a password", v.String())
+	}
+	plain.do(t, "auth", "adminpw")
+	if v := plain.do(t, "get", "app:1"); v.String() != "v" {
+		t.Fatal("get over plaintext error")
+	}
+
+	// a client without a certificate is rejected by mutual tls
+	nc, err = tls.Dial("tcp", tlsLn.Addr().String(), &tls.Config{RootCAs: roots})
+	if err == nil {
+		defer nc.Close()
+		nc.SetDeadline(time.Now().Add(time.Second))
+		nc.Write([]byte("PING\r\n"))
+		if _, err = resp.NewReader(nc).ReadValue(); err == nil {
+			t.Fatal("client without a certificate should be rejected")
+		}
+	}
+}
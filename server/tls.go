@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+// timeout of the TLS handshake of a new connection
+const tlsHandshakeTimeout = 10 * time.Second
+
+// TLSConf is the TLS configuration of a listener, like the tls-* configs of redis
+type TLSConf struct {
+	// certificate and private key of the server, PEM encoded
+	CertFile string
+	KeyFile  string
+	// CA certificates verifying the client certificates, clients must
+	// present a certificate signed by them when set (mutual TLS)
+	CACertFile string
+	// minimum TLS version, "1.2" or "1.3", default "1.2"
+	MinVersion string
+}
+
+// Config loads the files of conf and returns a config for ListenAndServeTLS
+func (conf *TLSConf) Config() (*tls.Config, error) {
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, errors.New("tls: need a cert file and a key file")
+	}
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := ParseTLSVersion(conf.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+	if conf.CACertFile != "" {
+		pem, err := ioutil.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificate in %s", conf.CACertFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ParseTLSVersion parses "1.2" or "1.3", optionally prefixed with "TLSv" like
+// redis, an empty version is 1.2
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(version), "tlsv") {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("tls: unsupported version %q", version)
+}
+
+// ListenAndServeTLS listens on the TCP address addr and calls ServeTLS, it
+// can run beside ListenAndServe on another port
+func (srv *Server) ListenAndServeTLS(addr string, config *tls.Config) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.ServeTLS(ln, config)
+}
+
+// ServeTLS accepts TLS connections on ln like Serve
+func (srv *Server) ServeTLS(ln net.Listener, config *tls.Config) error {
+	return srv.Serve(tls.NewListener(ln, config))
+}
+
+// handshake runs the TLS handshake of the connection, and authenticates it as
+// the acl user named by the CN of a verified client certificate when
+// Server.TLSClientCertUser is set, a CN without an enabled user is ignored
+func (c *conn) handshake(tc *tls.Conn) error {
+	tc.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	tc.SetDeadline(time.Time{})
+	state := tc.ConnectionState()
+	if !c.srv.TLSClientCertUser || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+	cn := state.PeerCertificates[0].Subject.CommonName
+	info, err := c.srv.cache.ACLGetUser(cn).Result()
+	if err == nil && info.Enabled {
+		c.user = cn
+		c.authed = true
+	}
+	return nil
+}